@@ -18,6 +18,9 @@ import (
 )
 
 const (
+	// AttachmentChecksumPrefix precedes the checksum in the comments of
+	// attachments uploaded by earlier versions. Comments are written by
+	// confluence.FormatAttachmentComment now, which still reads this format.
 	AttachmentChecksumPrefix = `mark:checksum: `
 )
 
@@ -67,11 +70,12 @@ func ResolveAttachments(
 		var same bool
 		for _, remote := range remotes {
 			if remote.Filename == attachment.Filename {
-				same = attachment.Checksum == strings.TrimPrefix(
+				meta, _ := confluence.ParseAttachmentComment(
 					remote.Metadata.Comment,
-					AttachmentChecksumPrefix,
 				)
 
+				same = attachment.Checksum == meta.Checksum
+
 				attachment.ID = remote.ID
 				attachment.Link = path.Join(
 					remote.Links.Context,
@@ -101,7 +105,7 @@ func ResolveAttachments(
 		info, err := api.CreateAttachment(
 			page.ID,
 			attachment.Filename,
-			AttachmentChecksumPrefix+attachment.Checksum,
+			attachmentComment(attachment),
 			bytes.NewReader(attachment.FileBytes),
 		)
 		if err != nil {
@@ -128,7 +132,7 @@ func ResolveAttachments(
 			page.ID,
 			attachment.ID,
 			attachment.Filename,
-			AttachmentChecksumPrefix+attachment.Checksum,
+			attachmentComment(attachment),
 			bytes.NewReader(attachment.FileBytes),
 		)
 		if err != nil {
@@ -159,6 +163,15 @@ func ResolveAttachments(
 	return attachments, nil
 }
 
+// attachmentComment returns the comment recording where the given attachment
+// came from and the checksum of its data.
+func attachmentComment(attachment Attachment) string {
+	return confluence.FormatAttachmentComment(confluence.AttachmentMeta{
+		Source:   attachment.Name,
+		Checksum: attachment.Checksum,
+	})
+}
+
 func ResolveLocalAttachments(opener vfs.Opener, base string, replacements []string) ([]Attachment, error) {
 	attachments, err := prepareAttachments(opener, base, replacements)
 	if err != nil {
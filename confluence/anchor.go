@@ -0,0 +1,25 @@
+package confluence
+
+import (
+	"strings"
+	"unicode"
+)
+
+// HeadingAnchor returns the anchor Confluence generates for a heading on the
+// given page, so it can be used as the fragment of an intra-page link.
+//
+// Confluence builds the anchor from the page title and the heading text with
+// all whitespace removed, joined by a hyphen; punctuation is preserved as is.
+func (api *API) HeadingAnchor(pageTitle, headingText string) string {
+	return stripSpaces(pageTitle) + "-" + stripSpaces(headingText)
+}
+
+func stripSpaces(text string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+
+		return r
+	}, text)
+}
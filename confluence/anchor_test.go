@@ -0,0 +1,39 @@
+package confluence
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeadingAnchor(t *testing.T) {
+	api := NewAPI("http://localhost", "user", "password")
+
+	tests := map[string]struct {
+		title   string
+		heading string
+		want    string
+	}{
+		"spaces": {
+			title:   "Release Notes",
+			heading: "Getting Started",
+			want:    "ReleaseNotes-GettingStarted",
+		},
+		"punctuation": {
+			title:   "FAQ",
+			heading: "What's new in v2.0?",
+			want:    "FAQ-What'snewinv2.0?",
+		},
+		"tabs and unicode": {
+			title:   "Über uns",
+			heading: "Kontakt\t& Impressum",
+			want:    "Überuns-Kontakt&Impressum",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, api.HeadingAnchor(tt.title, tt.heading))
+		})
+	}
+}
@@ -1,17 +1,27 @@
 package confluence
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"math/rand"
+	"mime"
 	"mime/multipart"
 	"net/http"
+	"net/http/cookiejar"
+	"net/textproto"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
+	"unicode"
 	"unicode/utf8"
 
 	"github.com/kovetskiy/gopencils"
@@ -32,8 +42,104 @@ type API struct {
 	// but it's only way to set permissions
 	json    *gopencils.Resource
 	BaseURL string
+
+	// AttachmentExpand lists the fields GetAttachments asks Confluence to
+	// expand for every attachment. Defaults to DefaultAttachmentExpand.
+	AttachmentExpand []string
+
+	// StorageTransform, if set, is applied to the storage-format body of a
+	// page right before CreatePage and UpdatePage send it to Confluence.
+	StorageTransform func(storage string) string
+
+	// CaptureUnknownFields makes REST responses keep top-level fields mark
+	// doesn't model, which helps to notice schema changes. They are logged
+	// at debug level and stored in UnknownFields of PageInfo and
+	// AttachmentInfo.
+	CaptureUnknownFields bool
+
+	// IndexDepth limits how many levels below the root page GenerateIndex
+	// descends. Defaults to DefaultIndexDepth.
+	IndexDepth int
+
+	// UploadConcurrency is the number of attachments UploadEachAttachment
+	// uploads at the same time. Defaults to DefaultUploadConcurrency.
+	UploadConcurrency int
+
+	// MaxAttachmentSize is the largest attachment in bytes the instance
+	// accepts, so larger ones are rejected before uploading them. Zero, the
+	// default, disables the check, as the limit is configurable per
+	// instance.
+	MaxAttachmentSize int64
+
+	// RetryAttempts is the number of times a request is sent before giving
+	// up on 429 (Too Many Requests) responses. Defaults to
+	// DefaultRetryAttempts.
+	RetryAttempts int
+
+	// Deployment overrides the detection of the kind of instance, which is
+	// based on its hostname. Calls taking a Deployment can override it
+	// again.
+	Deployment Deployment
+
+	// RetryOnConflict makes UpdatePage retry once on top of the current
+	// version of the page if the page was modified after it was retrieved,
+	// overwriting the modification. Otherwise UpdatePage fails.
+	RetryOnConflict bool
+
+	// MaxMaintenanceWait caps how long a retry of a request answered with
+	// 503 (Service Unavailable) waits for the time the Retry-After header
+	// asks for. Defaults to DefaultMaxMaintenanceWait.
+	MaxMaintenanceWait time.Duration
+
+	// Timeout limits how long a request may go without progress, i.e.
+	// without sending its body, receiving the response headers or reading
+	// the response body. Transfers of any size succeed as long as data keeps
+	// flowing. Requests that time out are retried up to RetryAttempts times.
+	// Defaults to DefaultTimeout; zero disables it.
+	Timeout time.Duration
+
+	// limiter is set by NewAPIWithOptions.
+	limiter Limiter
+
+	rateLimitMutex sync.Mutex
+	rateLimit      RateLimitInfo
+
+	appearanceMutex    sync.Mutex
+	defaultAppearances map[string]string
+}
+
+// RateLimitInfo is the rate-limit budget reported by Confluence Cloud in the
+// X-RateLimit-* headers of its responses.
+type RateLimitInfo struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+	NearLimit bool
 }
 
+// DefaultAttachmentExpand contains only what is needed to compare local and
+// remote attachments; the file size is always returned in extensions.
+var DefaultAttachmentExpand = []string{"version"}
+
+// DefaultIndexDepth is the default IndexDepth.
+const DefaultIndexDepth = 3
+
+// DefaultUploadConcurrency is the default UploadConcurrency.
+const DefaultUploadConcurrency = 4
+
+// DefaultMaxAttachmentSize is the attachment size limit of Confluence as
+// installed, 100 MB, for use as MaxAttachmentSize.
+const DefaultMaxAttachmentSize = 100 * 1024 * 1024
+
+// DefaultRetryAttempts is the default RetryAttempts.
+const DefaultRetryAttempts = 5
+
+// DefaultMaxMaintenanceWait is the default MaxMaintenanceWait.
+const DefaultMaxMaintenanceWait = 10 * time.Minute
+
+// DefaultTimeout is the default Timeout.
+const DefaultTimeout = 30 * time.Second
+
 type SpaceInfo struct {
 	ID   int    `json:"id"`
 	Key  string `json:"key"`
@@ -61,9 +167,93 @@ type PageInfo struct {
 		Title string `json:"title"`
 	} `json:"ancestors"`
 
+	// Space is only populated if the space is expanded.
+	Space struct {
+		Key string `json:"key"`
+	} `json:"space"`
+
 	Links struct {
 		Full string `json:"webui"`
 	} `json:"_links"`
+
+	// UnknownFields is only populated if API.CaptureUnknownFields is set.
+	UnknownFields map[string]json.RawMessage `json:"-"`
+}
+
+// MaxTitleLength is the maximum number of characters Confluence accepts in a
+// page title.
+const MaxTitleLength = 255
+
+// ErrTitleTooLong is returned when a page title exceeds MaxTitleLength.
+type ErrTitleTooLong struct {
+	Title  string
+	Length int
+}
+
+func (err ErrTitleTooLong) Error() string {
+	return fmt.Sprintf(
+		"page title is %d characters long, but Confluence allows at most %d: %q",
+		err.Length,
+		MaxTitleLength,
+		err.Title,
+	)
+}
+
+// validateTitle returns ErrTitleTooLong if Confluence would reject the given
+// title because of its length.
+func validateTitle(title string) error {
+	length := utf8.RuneCountInString(title)
+	if length > MaxTitleLength {
+		return ErrTitleTooLong{Title: title, Length: length}
+	}
+
+	return nil
+}
+
+// ErrAttachmentTooLarge is returned when an attachment exceeds
+// API.MaxAttachmentSize.
+type ErrAttachmentTooLarge struct {
+	Size  int64
+	Limit int64
+}
+
+func (err ErrAttachmentTooLarge) Error() string {
+	return fmt.Sprintf(
+		"attachment is %d bytes large, but the instance accepts at most %d",
+		err.Size,
+		err.Limit,
+	)
+}
+
+// CheckAttachmentSize returns ErrAttachmentTooLarge if the remaining content
+// of reader exceeds MaxAttachmentSize. The position of reader is left
+// unchanged.
+func (api *API) CheckAttachmentSize(reader io.ReadSeeker) error {
+	if api.MaxAttachmentSize <= 0 {
+		return nil
+	}
+
+	current, err := reader.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return karma.Format(err, "unable to determine attachment size")
+	}
+
+	end, err := reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		return karma.Format(err, "unable to determine attachment size")
+	}
+
+	_, err = reader.Seek(current, io.SeekStart)
+	if err != nil {
+		return karma.Format(err, "unable to determine attachment size")
+	}
+
+	size := end - current
+	if size > api.MaxAttachmentSize {
+		return ErrAttachmentTooLarge{Size: size, Limit: api.MaxAttachmentSize}
+	}
+
+	return nil
 }
 
 type AttachmentInfo struct {
@@ -72,10 +262,21 @@ type AttachmentInfo struct {
 	Metadata struct {
 		Comment string `json:"comment"`
 	} `json:"metadata"`
+	Version struct {
+		Number int64  `json:"number"`
+		When   string `json:"when"`
+	} `json:"version"`
+	Extensions struct {
+		MediaType string `json:"mediaType"`
+		FileSize  int64  `json:"fileSize"`
+	} `json:"extensions"`
 	Links struct {
 		Context  string `json:"context"`
 		Download string `json:"download"`
 	} `json:"_links"`
+
+	// UnknownFields is only populated if API.CaptureUnknownFields is set.
+	UnknownFields map[string]json.RawMessage `json:"-"`
 }
 
 type Label struct {
@@ -87,9 +288,23 @@ type LabelInfo struct {
 	Labels []Label `json:"results"`
 	Size   int     `json:"number"`
 }
+
+// form is the multipart form of an attachment upload. The form is written
+// while it's being sent, so the file is never held in memory as a whole.
 type form struct {
-	buffer io.Reader
-	writer *multipart.Writer
+	name    string
+	comment string
+	file    io.Reader
+
+	// contentType is the media type of file. It's guessed from the
+	// extension of name if empty.
+	contentType string
+
+	// offset is the position of file when the upload began, so that
+	// seekable files can be rewound to send them again on retries.
+	offset   int64
+	seekable bool
+	sent     bool
 }
 
 type tracer struct {
@@ -100,55 +315,273 @@ func (tracer *tracer) Printf(format string, args ...interface{}) {
 	log.Tracef(nil, tracer.prefix+" "+format, args...)
 }
 
+// NewAPI returns an API authenticating with HTTP basic auth, which is what
+// Confluence Cloud expects for an account email and API token and Confluence
+// Server and Data Center for a username and password.
+//
+// Deprecated behavior: for compatibility an empty username makes it use
+// password as a bearer token like NewAPIWithToken does, which is logged as
+// a warning. Call NewAPIWithToken instead.
 func NewAPI(baseURL string, username string, password string) *API {
-	var auth *gopencils.BasicAuth
-	if username != "" {
-		auth = &gopencils.BasicAuth{
-			Username: username,
-			Password: password,
-		}
-	}
-	rest := gopencils.Api(baseURL+"/rest/api", auth, 3) // set option for 3 retries on failure
+	return NewAPIWithClient(baseURL, username, password, nil)
+}
+
+// NewAPIWithToken returns an API authenticating with the given token in an
+// "Authorization: Bearer" header. That's what Confluence Server and Data
+// Center expect for personal access tokens and any flavor for OAuth 2.0
+// access tokens. Confluence Cloud API tokens are not bearer tokens; use
+// NewAPI with the account email instead.
+func NewAPIWithToken(baseURL string, token string) *API {
+	return newAPI(baseURL, nil, token, nil)
+}
+
+// NewAPIWithClient is like NewAPI, but sends all requests, both REST and
+// json-rpc ones, using the given client, e.g. one going through an
+// authenticated proxy. If client is nil, requests are sent by
+// http.DefaultTransport, which honors the HTTPS_PROXY, HTTP_PROXY and
+// NO_PROXY environment variables.
+func NewAPIWithClient(
+	baseURL string,
+	username string,
+	password string,
+	client *http.Client,
+) *API {
 	if username == "" {
-		if rest.Headers == nil {
-			rest.Headers = http.Header{}
-		}
-		rest.SetHeader("Authorization", fmt.Sprintf("Bearer %s", password))
+		log.Warningf(
+			nil,
+			"no username given, using the password as a bearer token; "+
+				"this fallback is deprecated, use NewAPIWithToken instead",
+		)
+
+		return newAPI(baseURL, nil, password, client)
+	}
+
+	return newAPI(
+		baseURL,
+		&gopencils.BasicAuth{Username: username, Password: password},
+		"",
+		client,
+	)
+}
+
+// newAPI returns an API authenticating with either auth or token.
+func newAPI(
+	baseURL string,
+	auth *gopencils.BasicAuth,
+	token string,
+	client *http.Client,
+) *API {
+	if client == nil {
+		jar, _ := cookiejar.New(nil)
+
+		client = &http.Client{Jar: jar}
+	}
+
+	// a context path like https://host/confluence is kept, but a trailing
+	// slash would double the one the API paths are joined with
+	baseURL = strings.TrimRight(baseURL, "/")
+
+	api := &API{
+		BaseURL: baseURL,
+
+		AttachmentExpand:   DefaultAttachmentExpand,
+		IndexDepth:         DefaultIndexDepth,
+		UploadConcurrency:  DefaultUploadConcurrency,
+		RetryAttempts:      DefaultRetryAttempts,
+		MaxMaintenanceWait: DefaultMaxMaintenanceWait,
+		Timeout:            DefaultTimeout,
 	}
 
-	json := gopencils.Api(baseURL+"/rpc/json-rpc/confluenceservice-v2", auth, 3)
+	// the client of the caller is left as it is
+	timed := *client
+	timed.Transport = &timeoutTransport{api: api, base: client.Transport}
+	client = &timed
+
+	rest := gopencils.Api(baseURL+"/rest/api", auth, client, 3) // set option for 3 retries on failure
+	json := gopencils.Api(baseURL+"/rpc/json-rpc/confluenceservice-v2", auth, client, 3)
+
+	if auth == nil {
+		for _, resource := range []*gopencils.Resource{rest, json} {
+			resource.Headers = http.Header{}
+			resource.SetHeader("Authorization", fmt.Sprintf("Bearer %s", token))
+		}
+	}
 
 	if log.GetLevel() == lorg.LevelTrace {
 		rest.Logger = &tracer{"rest:"}
 		json.Logger = &tracer{"json-rpc:"}
 	}
 
-	return &API{
-		rest:    rest,
-		json:    json,
-		BaseURL: strings.TrimSuffix(baseURL, "/"),
+	api.rest = rest
+	api.json = json
+
+	return api
+}
+
+// resource returns a new REST resource for the given path.
+func (api *API) resource(path string, result interface{}) *gopencils.Resource {
+	if api.CaptureUnknownFields && result != nil {
+		result = &unknownFieldsCapture{path: path, target: result}
+	}
+
+	return newResource(api.rest, path, result)
+}
+
+// rpc returns a new json-rpc resource for the given method.
+func (api *API) rpc(method string, result interface{}) *gopencils.Resource {
+	return newResource(api.json, method, result)
+}
+
+// instanceURL returns the URL of the given path relative to the root of the
+// instance rather than to the REST API, e.g. "/plugins/...".
+func (api *API) instanceURL(path string) url.URL {
+	target := *api.rest.Api.BaseUrl
+	target.Path = strings.TrimSuffix(
+		strings.TrimSuffix(target.Path, "/"),
+		"/rest/api",
+	) + path
+	target.RawQuery = ""
+
+	return target
+}
+
+// resourceContext is like resource but binds the requests to ctx.
+func (api *API) resourceContext(
+	ctx context.Context,
+	path string,
+	result interface{},
+) *gopencils.Resource {
+	return withContext(ctx, api.resource(path, result))
+}
+
+// rpcContext is like rpc but binds the requests to ctx.
+func (api *API) rpcContext(
+	ctx context.Context,
+	method string,
+	result interface{},
+) *gopencils.Resource {
+	return withContext(ctx, api.rpc(method, result))
+}
+
+// newResource creates a resource that doesn't share its headers with root.
+// gopencils writes into the headers of a resource while performing a request
+// and children share the headers map of their parent by default, so every
+// call gets a private copy to keep API safe for concurrent use.
+func newResource(
+	root *gopencils.Resource,
+	path string,
+	result interface{},
+) *gopencils.Resource {
+	resource := root.Res(path, result)
+
+	resource.Headers = root.Headers.Clone()
+	if resource.Headers == nil {
+		resource.Headers = http.Header{}
+	}
+
+	return resource
+}
+
+// withContext binds the requests of resource to ctx, so that they are
+// aborted once ctx is cancelled.
+//
+// gopencils retries failed requests with a back-off that can't be
+// interrupted, so these retries are disabled for cancellable contexts.
+func withContext(
+	ctx context.Context,
+	resource *gopencils.Resource,
+) *gopencils.Resource {
+	if ctx.Done() == nil {
+		return resource
+	}
+
+	api := *resource.Api
+	api.RetryCount = 0
+
+	client := *api.Client
+	client.Transport = &contextTransport{ctx: ctx, base: client.Transport}
+	api.Client = &client
+
+	resource.Api = &api
+
+	return resource
+}
+
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (transport *contextTransport) RoundTrip(
+	request *http.Request,
+) (*http.Response, error) {
+	base := transport.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return base.RoundTrip(request.WithContext(transport.ctx))
+}
+
+// sleepContext pauses for the given duration or until ctx is cancelled.
+func sleepContext(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// contextReader stops reading from reader once ctx is cancelled.
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (reader *contextReader) Read(data []byte) (int, error) {
+	err := reader.ctx.Err()
+	if err != nil {
+		return 0, err
 	}
+
+	return reader.reader.Read(data)
 }
 
 // doWithRetry executes fn up to attempts times while the returned
-// *http.Response has status 429 or 5xx.
-// It applies exponential back-off with jitter between retries.
-func doWithRetry(
+// *http.Response has status 429 or 503.
+// It applies exponential back-off with jitter between retries, waiting at
+// least as long as the Retry-After header of the response asks for. A 503
+// (Service Unavailable) response that is still returned after the last
+// attempt is passed on to the caller.
+func (api *API) doWithRetry(
 	ctx context.Context,
 	attempts int,
 	fn func() (*http.Response, error),
 ) (*http.Response, error) {
 	var (
-		resp *http.Response
-		err  error
+		resp       *http.Response
+		err        error
+		retryAfter time.Duration
 	)
 
+	if attempts < 1 {
+		attempts = 1
+	}
+
 	// 1s, 2s, 4s … with ±25 % jitter
 	base := time.Second
 	for i := 0; i < attempts; i++ {
 		if i > 0 {
 			jitter := time.Duration(rand.Int63n(int64(base/4))) - base/8
 			sleep := base + jitter
+			if sleep < retryAfter {
+				sleep = retryAfter + time.Duration(rand.Int63n(int64(base/4)))
+			}
+
 			select {
 			case <-time.After(sleep):
 			case <-ctx.Done():
@@ -157,15 +590,40 @@ func doWithRetry(
 			base *= 2
 		}
 
+		if api.limiter != nil {
+			err = api.limiter.Wait(ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		resp, err = fn()
 		if err != nil {
+			// requests that ran into Timeout get another attempt with a
+			// fresh timeout, as gopencils doesn't retry requests bound to a
+			// cancellable context, see withContext
+			if errors.Is(err, ErrTimeout) && i < attempts-1 {
+				retryAfter = 0
+				continue
+			}
+
 			return nil, err
 		}
 
-		if resp.StatusCode != http.StatusTooManyRequests {
+		api.captureRateLimit(resp.Header)
+
+		switch resp.StatusCode {
+		case http.StatusTooManyRequests:
+		case http.StatusServiceUnavailable:
+			if i == attempts-1 {
+				return resp, nil
+			}
+		default:
 			return resp, nil
 		}
 
+		retryAfter = api.retryAfter(resp, time.Now())
+
 		// Fully drain body so the connection can be re-used.
 		_, _ = io.Copy(io.Discard, resp.Body)
 		_ = resp.Body.Close()
@@ -176,6 +634,99 @@ func doWithRetry(
 	)
 }
 
+// retryAfter returns how long the Retry-After header of the given response
+// asks to wait. Confluence answers with 503 (Service Unavailable) during
+// maintenance, possibly asking to wait for a long time, so the delay is
+// capped at MaxMaintenanceWait for such responses.
+func (api *API) retryAfter(resp *http.Response, now time.Time) time.Duration {
+	wait := parseRetryAfter(resp.Header.Get("Retry-After"), now)
+
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		return min(wait, api.MaxMaintenanceWait)
+	}
+
+	return wait
+}
+
+// parseRetryAfter returns the delay the given Retry-After header value asks
+// for, which is either a number of seconds or an HTTP date. Zero is returned
+// if the value is empty or can't be parsed.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	seconds, err := strconv.Atoi(value)
+	if err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+
+	date, err := http.ParseTime(value)
+	if err == nil {
+		return max(date.Sub(now), 0)
+	}
+
+	return 0
+}
+
+// nextPageQuery extracts the query parameters of the next page from the
+// _links.next field of a paginated response. It returns nil when there are no
+// more pages.
+func nextPageQuery(next string) (map[string]string, error) {
+	if next == "" {
+		return nil, nil
+	}
+
+	link, err := url.Parse(next)
+	if err != nil {
+		return nil, karma.Format(err, "unable to parse next page link: %q", next)
+	}
+
+	query := map[string]string{}
+	for key, values := range link.Query() {
+		query[key] = values[0]
+	}
+
+	return query, nil
+}
+
+// LastRateLimit returns the rate-limit budget captured from the most recent
+// response carrying X-RateLimit-* headers. The zero value is returned if no
+// such response has been received yet, which is always the case for
+// Confluence Server.
+func (api *API) LastRateLimit() RateLimitInfo {
+	api.rateLimitMutex.Lock()
+	defer api.rateLimitMutex.Unlock()
+
+	return api.rateLimit
+}
+
+func (api *API) captureRateLimit(header http.Header) {
+	limit := header.Get("X-RateLimit-Limit")
+	remaining := header.Get("X-RateLimit-Remaining")
+	if limit == "" && remaining == "" {
+		return
+	}
+
+	var info RateLimitInfo
+
+	info.Limit, _ = strconv.Atoi(limit)
+	info.Remaining, _ = strconv.Atoi(remaining)
+	info.NearLimit, _ = strconv.ParseBool(header.Get("X-RateLimit-NearLimit"))
+
+	if reset := header.Get("X-RateLimit-Reset"); reset != "" {
+		if at, err := time.Parse(time.RFC3339, reset); err == nil {
+			info.Reset = at
+		} else if seconds, err := strconv.ParseInt(reset, 10, 64); err == nil {
+			info.Reset = time.Unix(seconds, 0)
+		}
+	}
+
+	api.rateLimitMutex.Lock()
+	api.rateLimit = info
+	api.rateLimitMutex.Unlock()
+}
+
 func (api *API) FindRootPage(space string) (*PageInfo, error) {
 	page, err := api.FindPage(space, ``, "page")
 	if err != nil {
@@ -204,39 +755,38 @@ func (api *API) FindRootPage(space string) (*PageInfo, error) {
 }
 
 func (api *API) FindHomePage(space string) (*PageInfo, error) {
-	var result SpaceInfo
-	payload := map[string]string{
-		"expand": "homepage",
-	}
+	return api.FindHomePageContext(context.Background(), space)
+}
 
-	reqFn := func() (*http.Response, error) {
-		req, err := api.rest.Res("space/"+space, &result).Get(payload)
-		if err != nil {
-			return nil, err
-		}
-		return req.Raw, nil
-	}
-	resp, err := doWithRetry(context.Background(), 5, reqFn)
+// FindHomePageContext is like FindHomePage but aborts once ctx is cancelled.
+func (api *API) FindHomePageContext(ctx context.Context, space string) (*PageInfo, error) {
+	info, err := api.GetSpaceContext(ctx, space)
 	if err != nil {
 		return nil, err
 	}
 
-	if resp.StatusCode == http.StatusTooManyRequests {
-		time.Sleep(1 * time.Second)
-		return api.FindHomePage(space)
-	}
-
-	if resp.StatusCode == http.StatusNotFound || resp.StatusCode != http.StatusOK {
-		return nil, newErrorStatus(resp)
-	}
-
-	return &result.Homepage, nil
+	return &info.Homepage, nil
 }
 
 func (api *API) FindPage(
 	space string,
 	title string,
 	pageType string,
+) (*PageInfo, error) {
+	return api.FindPageContext(
+		context.Background(),
+		space,
+		title,
+		pageType,
+	)
+}
+
+// FindPageContext is like FindPage but aborts once ctx is cancelled.
+func (api *API) FindPageContext(
+	ctx context.Context,
+	space string,
+	title string,
+	pageType string,
 ) (*PageInfo, error) {
 	result := struct {
 		Results []PageInfo `json:"results"`
@@ -244,7 +794,7 @@ func (api *API) FindPage(
 
 	payload := map[string]string{
 		"spaceKey": space,
-		"expand":   "ancestors,version",
+		"expand":   "ancestors,version,space",
 		"type":     pageType,
 	}
 
@@ -253,7 +803,8 @@ func (api *API) FindPage(
 	}
 
 	reqFn := func() (*http.Response, error) {
-		req, err := api.rest.Res(
+		req, err := api.resourceContext(
+			ctx,
 			"content/", &result,
 		).Get(payload)
 		if err != nil {
@@ -262,14 +813,18 @@ func (api *API) FindPage(
 		return req.Raw, nil
 	}
 
-	resp, err := doWithRetry(context.Background(), 5, reqFn)
+	resp, err := api.doWithRetry(ctx, api.RetryAttempts, reqFn)
 	if err != nil {
 		return nil, err
 	}
 
 	if resp.StatusCode == http.StatusTooManyRequests {
-		time.Sleep(1 * time.Second)
-		return api.FindPage(space, title, pageType)
+		err = sleepContext(ctx, 1*time.Second)
+		if err != nil {
+			return nil, err
+		}
+
+		return api.FindPageContext(ctx, space, title, pageType)
 	}
 
 	// allow 404 because it's fine if page is not found,
@@ -291,13 +846,46 @@ func (api *API) CreateAttachment(
 	comment string,
 	reader io.Reader,
 ) (AttachmentInfo, error) {
-	var info AttachmentInfo
+	return api.CreateAttachmentContext(
+		context.Background(),
+		pageID,
+		name,
+		comment,
+		reader,
+	)
+}
+
+// CreateAttachmentContext is like CreateAttachment but aborts reading from
+// reader and the upload itself once ctx is cancelled.
+func (api *API) CreateAttachmentContext(
+	ctx context.Context,
+	pageID string,
+	name string,
+	comment string,
+	reader io.Reader,
+) (AttachmentInfo, error) {
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		err := api.CheckAttachmentSize(seeker)
+		if err != nil {
+			return AttachmentInfo{}, err
+		}
+	}
 
-	form, err := getAttachmentPayload(name, comment, reader)
+	form, err := getAttachmentPayload(ctx, name, comment, reader)
 	if err != nil {
 		return AttachmentInfo{}, err
 	}
 
+	return api.createAttachment(ctx, pageID, form)
+}
+
+func (api *API) createAttachment(
+	ctx context.Context,
+	pageID string,
+	form *form,
+) (AttachmentInfo, error) {
+	var info AttachmentInfo
+
 	var result struct {
 		Links struct {
 			Context string `json:"context"`
@@ -305,36 +893,27 @@ func (api *API) CreateAttachment(
 		Results []AttachmentInfo `json:"results"`
 	}
 
-	resource := api.rest.Res(
-		"content/"+pageID+"/child/attachment", &result,
-	)
-
-	resource.Payload = form.buffer
-	oldHeaders := resource.Headers.Clone()
-	resource.Headers = http.Header{}
-	if resource.Api.BasicAuth == nil {
-		resource.Headers.Set("Authorization", oldHeaders.Get("Authorization"))
-	}
-
-	resource.SetHeader("Content-Type", form.writer.FormDataContentType())
-	resource.SetHeader("X-Atlassian-Token", "no-check")
-
 	reqFn := func() (*http.Response, error) {
-		request, err := resource.Post()
-		if err != nil {
-			return nil, err
-		}
-		return request.Raw, nil
+		return api.postAttachment(
+			ctx,
+			"content/"+pageID+"/child/attachment",
+			form,
+			&result,
+		)
 	}
 
-	resp, err := doWithRetry(context.Background(), 5, reqFn)
+	resp, err := api.doWithRetry(ctx, api.RetryAttempts, reqFn)
 	if err != nil {
 		return info, err
 	}
 
 	if resp.StatusCode == http.StatusTooManyRequests {
-		time.Sleep(1 * time.Second)
-		return api.CreateAttachment(pageID, name, comment, reader)
+		err = sleepContext(ctx, 1*time.Second)
+		if err != nil {
+			return info, err
+		}
+
+		return api.createAttachment(ctx, pageID, form)
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -361,8 +940,9 @@ func (api *API) CreateAttachment(
 	return info, nil
 }
 
-// UpdateAttachment uploads a new version of the same attachment if the
-// checksums differs from the previous one.
+// UpdateAttachment uploads a new version of the same attachment. It always
+// uploads; use EnsureAttachment to skip attachments whose checksum didn't
+// change.
 // It also handles a case where Confluence returns sort of "short" variant of
 // the response instead of an extended one.
 func (api *API) UpdateAttachment(
@@ -372,13 +952,49 @@ func (api *API) UpdateAttachment(
 	comment string,
 	reader io.Reader,
 ) (AttachmentInfo, error) {
-	var info AttachmentInfo
+	return api.UpdateAttachmentContext(
+		context.Background(),
+		pageID,
+		attachID,
+		name,
+		comment,
+		reader,
+	)
+}
+
+// UpdateAttachmentContext is like UpdateAttachment but aborts reading from
+// reader and the upload itself once ctx is cancelled.
+func (api *API) UpdateAttachmentContext(
+	ctx context.Context,
+	pageID string,
+	attachID string,
+	name string,
+	comment string,
+	reader io.Reader,
+) (AttachmentInfo, error) {
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		err := api.CheckAttachmentSize(seeker)
+		if err != nil {
+			return AttachmentInfo{}, err
+		}
+	}
 
-	form, err := getAttachmentPayload(name, comment, reader)
+	form, err := getAttachmentPayload(ctx, name, comment, reader)
 	if err != nil {
 		return AttachmentInfo{}, err
 	}
 
+	return api.updateAttachment(ctx, pageID, attachID, form)
+}
+
+func (api *API) updateAttachment(
+	ctx context.Context,
+	pageID string,
+	attachID string,
+	form *form,
+) (AttachmentInfo, error) {
+	var info AttachmentInfo
+
 	var extendedResponse struct {
 		Links struct {
 			Context string `json:"context"`
@@ -388,37 +1004,28 @@ func (api *API) UpdateAttachment(
 
 	var result json.RawMessage
 
-	resource := api.rest.Res(
-		"content/"+pageID+"/child/attachment/"+attachID+"/data", &result,
-	)
-
-	resource.Payload = form.buffer
-	oldHeaders := resource.Headers.Clone()
-	resource.Headers = http.Header{}
-	if resource.Api.BasicAuth == nil {
-		resource.Headers.Set("Authorization", oldHeaders.Get("Authorization"))
-	}
-
-	resource.SetHeader("Content-Type", form.writer.FormDataContentType())
-	resource.SetHeader("X-Atlassian-Token", "no-check")
-
 	reqFn := func() (*http.Response, error) {
-		request, err := resource.Post()
-		if err != nil {
-			return nil, err
-		}
-		return request.Raw, nil
+		return api.postAttachment(
+			ctx,
+			"content/"+pageID+"/child/attachment/"+attachID+"/data",
+			form,
+			&result,
+		)
 	}
 
-	resp, err := doWithRetry(context.Background(), 5, reqFn)
+	resp, err := api.doWithRetry(ctx, api.RetryAttempts, reqFn)
 	if err != nil {
 		return info, err
 	}
 
 	if resp.StatusCode == http.StatusTooManyRequests {
-		time.Sleep(1 * time.Second)
-		return api.UpdateAttachment(pageID, attachID, name, comment, reader)
-	}
+		err = sleepContext(ctx, 1*time.Second)
+		if err != nil {
+			return info, err
+		}
+
+		return api.updateAttachment(ctx, pageID, attachID, form)
+	}
 
 	if resp.StatusCode != http.StatusOK {
 		return info, newErrorStatus(resp)
@@ -460,23 +1067,113 @@ func (api *API) UpdateAttachment(
 	return shortResponse, nil
 }
 
-func getAttachmentPayload(name, comment string, reader io.Reader) (*form, error) {
-	var (
-		payload = bytes.NewBuffer(nil)
-		writer  = multipart.NewWriter(payload)
+// postAttachment posts form to the given path. Every call writes form from
+// the start, so that retried uploads send the whole file again.
+func (api *API) postAttachment(
+	ctx context.Context,
+	path string,
+	form *form,
+	result interface{},
+) (*http.Response, error) {
+	reader, contentType, err := form.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	header.Set("X-Atlassian-Token", "no-check")
+
+	return api.streamRequest(ctx, http.MethodPost, path, reader, header, result)
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func getAttachmentPayload(
+	ctx context.Context,
+	name string,
+	comment string,
+	reader io.Reader,
+) (*form, error) {
+	form := &form{
+		name:    name,
+		comment: comment,
+		file:    reader,
+	}
+
+	if seeker, ok := reader.(io.Seeker); ok {
+		offset, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, karma.Format(err, "unable to determine file offset")
+		}
+
+		form.offset = offset
+		form.seekable = true
+	}
+
+	return form, nil
+}
+
+// open returns a pipe the form is written to in the background and the
+// content type of the form. Files that can't be rewound can be read only
+// once, so the form of such a file can't be opened again.
+func (form *form) open(ctx context.Context) (*io.PipeReader, string, error) {
+	if form.sent {
+		if !form.seekable {
+			return nil, "", karma.
+				Describe("name", form.name).
+				Reason("unable to send attachment again: file can't be rewound")
+		}
+
+		_, err := form.file.(io.Seeker).Seek(form.offset, io.SeekStart)
+		if err != nil {
+			return nil, "", karma.Format(err, "unable to rewind file")
+		}
+	}
+
+	form.sent = true
+
+	reader, pipe := io.Pipe()
+	writer := multipart.NewWriter(pipe)
+
+	go func() {
+		pipe.CloseWithError(form.write(ctx, writer))
+	}()
+
+	return reader, writer.FormDataContentType(), nil
+}
+
+func (form *form) write(ctx context.Context, writer *multipart.Writer) error {
+	contentType := form.contentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(form.name))
+	}
+
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	header := textproto.MIMEHeader{}
+	header.Set(
+		"Content-Disposition",
+		fmt.Sprintf(
+			`form-data; name="file"; filename="%s"`,
+			quoteEscaper.Replace(form.name),
+		),
 	)
+	header.Set("Content-Type", contentType)
 
-	content, err := writer.CreateFormFile("file", name)
+	content, err := writer.CreatePart(header)
 	if err != nil {
-		return nil, karma.Format(
+		return karma.Format(
 			err,
 			"unable to create form file",
 		)
 	}
 
-	_, err = io.Copy(content, reader)
+	_, err = io.Copy(content, &contextReader{ctx: ctx, reader: form.file})
 	if err != nil {
-		return nil, karma.Format(
+		return karma.Format(
 			err,
 			"unable to copy i/o between form-file and file",
 		)
@@ -484,15 +1181,15 @@ func getAttachmentPayload(name, comment string, reader io.Reader) (*form, error)
 
 	commentWriter, err := writer.CreateFormField("comment")
 	if err != nil {
-		return nil, karma.Format(
+		return karma.Format(
 			err,
 			"unable to create form field for comment",
 		)
 	}
 
-	_, err = commentWriter.Write([]byte(comment))
+	_, err = commentWriter.Write([]byte(form.comment))
 	if err != nil {
-		return nil, karma.Format(
+		return karma.Format(
 			err,
 			"unable to write comment in form-field",
 		)
@@ -500,87 +1197,133 @@ func getAttachmentPayload(name, comment string, reader io.Reader) (*form, error)
 
 	err = writer.Close()
 	if err != nil {
-		return nil, karma.Format(
+		return karma.Format(
 			err,
 			"unable to close form-writer",
 		)
 	}
 
-	return &form{
-		buffer: payload,
-		writer: writer,
-	}, nil
+	return nil
 }
 
 func (api *API) GetAttachments(pageID string) ([]AttachmentInfo, error) {
-	result := struct {
-		Links struct {
-			Context string `json:"context"`
-		} `json:"_links"`
-		Results []AttachmentInfo `json:"results"`
-	}{}
+	return api.GetAttachmentsContext(context.Background(), pageID)
+}
 
-	payload := map[string]string{
-		"expand": "version,container",
-		"limit":  "1000",
+// GetAttachmentsContext is like GetAttachments but aborts once ctx is
+// cancelled.
+func (api *API) GetAttachmentsContext(ctx context.Context, pageID string) ([]AttachmentInfo, error) {
+	query := map[string]string{
+		"limit": "1000",
+	}
+
+	if len(api.AttachmentExpand) > 0 {
+		query["expand"] = strings.Join(api.AttachmentExpand, ",")
+	}
+
+	attachments := []AttachmentInfo{}
+	for query != nil {
+		var result attachmentList
+
+		err := api.getAttachmentList(ctx, pageID, query, &result)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, info := range result.Results {
+			if info.Links.Context == "" {
+				info.Links.Context = result.Links.Context
+			}
+
+			attachments = append(attachments, info)
+		}
+
+		query, err = nextPageQuery(result.Links.Next)
+		if err != nil {
+			return nil, err
+		}
 	}
 
+	return attachments, nil
+}
+
+type attachmentList struct {
+	Links struct {
+		Context string `json:"context"`
+		Next    string `json:"next"`
+	} `json:"_links"`
+	Results []AttachmentInfo `json:"results"`
+}
+
+func (api *API) getAttachmentList(
+	ctx context.Context,
+	pageID string,
+	query map[string]string,
+	result *attachmentList,
+) error {
 	reqFn := func() (*http.Response, error) {
-		request, err := api.rest.Res(
-			"content/"+pageID+"/child/attachment", &result,
-		).Get(payload)
+		request, err := api.resourceContext(
+			ctx,
+			"content/"+pageID+"/child/attachment", result,
+		).Get(query)
 		if err != nil {
 			return nil, err
 		}
 		return request.Raw, nil
 	}
 
-	resp, err := doWithRetry(context.Background(), 5, reqFn)
+	resp, err := api.doWithRetry(ctx, api.RetryAttempts, reqFn)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	if resp.StatusCode == http.StatusTooManyRequests {
-		time.Sleep(1 * time.Second)
-		return api.GetAttachments(pageID)
-	}
+		err = sleepContext(ctx, 1*time.Second)
+		if err != nil {
+			return err
+		}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, newErrorStatus(resp)
+		return api.getAttachmentList(ctx, pageID, query, result)
 	}
 
-	for i, info := range result.Results {
-		if info.Links.Context == "" {
-			info.Links.Context = result.Links.Context
-		}
-
-		result.Results[i] = info
+	if resp.StatusCode != http.StatusOK {
+		return newErrorStatus(resp)
 	}
 
-	return result.Results, nil
+	return nil
 }
 
 func (api *API) GetPageByID(pageID string) (*PageInfo, error) {
+	return api.GetPageByIDContext(context.Background(), pageID)
+}
+
+// GetPageByIDContext is like GetPageByID but aborts once ctx is cancelled.
+func (api *API) GetPageByIDContext(ctx context.Context, pageID string) (*PageInfo, error) {
 
 	var page PageInfo
 	reqFn := func() (*http.Response, error) {
-		request, err := api.rest.Res(
+		request, err := api.resourceContext(
+			ctx,
 			"content/"+pageID, &page,
-		).Get(map[string]string{"expand": "ancestors,version"})
+		).Get(map[string]string{"expand": "ancestors,version,space"})
 		if err != nil {
 			return nil, err
 		}
 		return request.Raw, nil
 	}
 
-	resp, err := doWithRetry(context.Background(), 5, reqFn)
+	resp, err := api.doWithRetry(ctx, api.RetryAttempts, reqFn)
 	if err != nil {
 		return nil, err
 	}
 
 	if resp.StatusCode == http.StatusTooManyRequests {
-		time.Sleep(1 * time.Second)
-		return api.GetPageByID(pageID)
+		err = sleepContext(ctx, 1*time.Second)
+		if err != nil {
+			return nil, err
+		}
+
+		return api.GetPageByIDContext(ctx, pageID)
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -597,6 +1340,73 @@ func (api *API) CreatePage(
 	title string,
 	body string,
 ) (*PageInfo, error) {
+	return api.CreatePageContext(
+		context.Background(),
+		space,
+		pageType,
+		parent,
+		title,
+		body,
+	)
+}
+
+// CreatePageContext is like CreatePage but aborts once ctx is cancelled.
+func (api *API) CreatePageContext(
+	ctx context.Context,
+	space string,
+	pageType string,
+	parent *PageInfo,
+	title string,
+	body string,
+) (*PageInfo, error) {
+	return api.CreatePageWithDateContext(
+		ctx,
+		space,
+		pageType,
+		parent,
+		title,
+		body,
+		time.Time{},
+	)
+}
+
+// CreatePageWithDate is like CreatePage but backdates the page to the given
+// creation date. A zero date leaves the creation date to Confluence.
+func (api *API) CreatePageWithDate(
+	space string,
+	pageType string,
+	parent *PageInfo,
+	title string,
+	body string,
+	createdDate time.Time,
+) (*PageInfo, error) {
+	return api.CreatePageWithDateContext(
+		context.Background(),
+		space,
+		pageType,
+		parent,
+		title,
+		body,
+		createdDate,
+	)
+}
+
+// CreatePageWithDateContext is like CreatePageWithDate but aborts once ctx
+// is cancelled.
+func (api *API) CreatePageWithDateContext(
+	ctx context.Context,
+	space string,
+	pageType string,
+	parent *PageInfo,
+	title string,
+	body string,
+	createdDate time.Time,
+) (*PageInfo, error) {
+	err := validateTitle(title)
+	if err != nil {
+		return nil, err
+	}
+
 	payload := map[string]interface{}{
 		"type":  pageType,
 		"title": title,
@@ -606,7 +1416,7 @@ func (api *API) CreatePage(
 		"body": map[string]interface{}{
 			"storage": map[string]interface{}{
 				"representation": "storage",
-				"value":          body,
+				"value":          api.transformStorage(body),
 			},
 		},
 		"metadata": map[string]interface{}{
@@ -618,15 +1428,31 @@ func (api *API) CreatePage(
 		},
 	}
 
-	if parent != nil {
+	// Confluence rejects ancestors on blog posts, which live in the blog of
+	// the space rather than the page tree.
+	if parent != nil && pageType != "blogpost" {
 		payload["ancestors"] = []map[string]interface{}{
 			{"id": parent.ID},
 		}
 	}
 
+	// Confluence honors the creation date only where backdating is
+	// supported (e.g. Server or Cloud imports) and ignores it otherwise.
+	// For blog posts it's the posting day, which dates the post in the blog.
+	if !createdDate.IsZero() {
+		payload["history"] = map[string]interface{}{
+			"createdDate": createdDate.UTC().Format(time.RFC3339),
+		}
+	}
+
 	var page PageInfo
 	reqFn := func() (*http.Response, error) {
-		request, err := api.rest.Res(
+		if len(body) >= streamThreshold {
+			return api.streamJSON(ctx, http.MethodPost, "content/", payload, &page)
+		}
+
+		request, err := api.resourceContext(
+			ctx,
 			"content/", &page,
 		).Post(payload)
 		if err != nil {
@@ -635,14 +1461,18 @@ func (api *API) CreatePage(
 		return request.Raw, nil
 	}
 
-	resp, err := doWithRetry(context.Background(), 5, reqFn)
+	resp, err := api.doWithRetry(ctx, api.RetryAttempts, reqFn)
 	if err != nil {
 		return nil, err
 	}
 
 	if resp.StatusCode == http.StatusTooManyRequests {
-		time.Sleep(1 * time.Second)
-		return api.CreatePage(space, pageType, parent, title, body)
+		err = sleepContext(ctx, 1*time.Second)
+		if err != nil {
+			return nil, err
+		}
+
+		return api.CreatePageWithDateContext(ctx, space, pageType, parent, title, body, createdDate)
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -652,7 +1482,43 @@ func (api *API) CreatePage(
 	return &page, nil
 }
 
-func (api *API) UpdatePage(page *PageInfo, newContent string, minorEdit bool, versionMessage string, newLabels []string, appearance string, emojiString string) error {
+// UpdatePage replaces the content of the given page. If skipIfUnchanged is
+// set, the page is left as it is, without creating a new version, if neither
+// its body nor its labels, appearance or emoji would change.
+func (api *API) UpdatePage(page *PageInfo, newContent string, minorEdit bool, versionMessage string, newLabels []string, appearance string, emojiString string, skipIfUnchanged bool) error {
+	return api.UpdatePageContext(context.Background(), page, newContent, minorEdit, versionMessage, newLabels, appearance, emojiString, skipIfUnchanged)
+}
+
+// UpdatePageContext is like UpdatePage but aborts once ctx is cancelled.
+func (api *API) UpdatePageContext(ctx context.Context, page *PageInfo, newContent string, minorEdit bool, versionMessage string, newLabels []string, appearance string, emojiString string, skipIfUnchanged bool) error {
+	err := validateTitle(page.Title)
+	if err != nil {
+		return err
+	}
+
+	if appearance == "" {
+		appearance = api.defaultAppearance(page.Space.Key)
+	}
+
+	if skipIfUnchanged {
+		unchanged, err := api.isPageUnchanged(
+			ctx,
+			page.ID,
+			api.transformStorage(newContent),
+			newLabels,
+			appearance,
+			emojiString,
+		)
+		if err != nil {
+			return karma.Format(err, "unable to compare page %q", page.Title)
+		}
+
+		if unchanged {
+			log.Debugf(nil, "page %q is unchanged, skipping update", page.Title)
+			return nil
+		}
+	}
+
 	nextPageVersion := page.Version.Number + 1
 	oldAncestors := []map[string]interface{}{}
 
@@ -675,8 +1541,7 @@ func (api *API) UpdatePage(page *PageInfo, newContent string, minorEdit bool, ve
 	}
 
 	if emojiString != "" {
-		r, _ := utf8.DecodeRuneInString(emojiString)
-		unicodeHex := fmt.Sprintf("%x", r)
+		unicodeHex := emojiHex(emojiString)
 
 		properties["emoji-title-draft"] = map[string]interface{}{
 			"value": unicodeHex,
@@ -686,19 +1551,21 @@ func (api *API) UpdatePage(page *PageInfo, newContent string, minorEdit bool, ve
 		}
 	}
 
+	version := map[string]interface{}{
+		"number":    nextPageVersion,
+		"minorEdit": minorEdit,
+		"message":   versionMessage,
+	}
+
 	payload := map[string]interface{}{
-		"id":    page.ID,
-		"type":  page.Type,
-		"title": page.Title,
-		"version": map[string]interface{}{
-			"number":    nextPageVersion,
-			"minorEdit": minorEdit,
-			"message":   versionMessage,
-		},
+		"id":        page.ID,
+		"type":      page.Type,
+		"title":     page.Title,
+		"version":   version,
 		"ancestors": oldAncestors,
 		"body": map[string]interface{}{
 			"storage": map[string]interface{}{
-				"value":          newContent,
+				"value":          api.transformStorage(newContent),
 				"representation": "storage",
 			},
 		},
@@ -708,7 +1575,18 @@ func (api *API) UpdatePage(page *PageInfo, newContent string, minorEdit bool, ve
 	}
 
 	reqFn := func() (*http.Response, error) {
-		request, err := api.rest.Res(
+		if len(newContent) >= streamThreshold {
+			return api.streamJSON(
+				ctx,
+				http.MethodPut,
+				"content/"+page.ID,
+				payload,
+				&map[string]interface{}{},
+			)
+		}
+
+		request, err := api.resourceContext(
+			ctx,
 			"content/"+page.ID, &map[string]interface{}{},
 		).Put(payload)
 		if err != nil {
@@ -717,136 +1595,676 @@ func (api *API) UpdatePage(page *PageInfo, newContent string, minorEdit bool, ve
 		return request.Raw, nil
 	}
 
-	resp, err := doWithRetry(context.Background(), 5, reqFn)
+	resp, err := api.doWithRetry(ctx, api.RetryAttempts, reqFn)
 	if err != nil {
 		return err
 	}
 
-	if resp.StatusCode == http.StatusTooManyRequests {
-		time.Sleep(1 * time.Second)
-		return api.UpdatePage(page, newContent, minorEdit, versionMessage, newLabels, appearance, emojiString)
-	}
+	if resp.StatusCode == http.StatusConflict && api.RetryOnConflict {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return newErrorStatus(resp)
-	}
+		fresh, err := api.GetPageByIDContext(ctx, page.ID)
+		if err != nil {
+			return karma.Format(
+				err,
+				"unable to retrieve current version of page %q",
+				page.Title,
+			)
+		}
 
-	return nil
-}
+		log.Debugf(
+			nil,
+			"page %q was modified concurrently, retrying on top of version %d",
+			page.Title,
+			fresh.Version.Number,
+		)
 
-func (api *API) AddPageLabels(page *PageInfo, newLabels []string) (*LabelInfo, error) {
+		version["number"] = fresh.Version.Number + 1
 
-	labels := []map[string]interface{}{}
-	for _, label := range newLabels {
-		if label != "" {
-			item := map[string]interface{}{
-				"prefix": "global",
-				"name":   label,
-			}
-			labels = append(labels, item)
+		resp, err = api.doWithRetry(ctx, api.RetryAttempts, reqFn)
+		if err != nil {
+			return err
 		}
 	}
 
-	payload := labels
-
-	var labelInfo LabelInfo
-	reqFn := func() (*http.Response, error) {
-		request, err := api.rest.Res(
-			"content/"+page.ID+"/label", &labelInfo,
-		).Post(payload)
+	if resp.StatusCode == http.StatusTooManyRequests {
+		err = sleepContext(ctx, 1*time.Second)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		return request.Raw, nil
-	}
 
-	resp, err := doWithRetry(context.Background(), 5, reqFn)
-	if err != nil {
-		return nil, err
+		return api.UpdatePageContext(ctx, page, newContent, minorEdit, versionMessage, newLabels, appearance, emojiString, false)
 	}
 
-	if resp.StatusCode == http.StatusTooManyRequests {
-		time.Sleep(1 * time.Second)
-		return api.AddPageLabels(page, newLabels)
+	if resp.StatusCode == http.StatusConflict {
+		return karma.Format(
+			newErrorStatus(resp),
+			"page %q was modified concurrently",
+			page.Title,
+		)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, newErrorStatus(resp)
+		return newErrorStatus(resp)
 	}
 
-	return &labelInfo, nil
+	return nil
 }
 
-func (api *API) DeletePageLabel(page *PageInfo, label string) (*LabelInfo, error) {
+// UpdatePageWithTemplate is like UpdatePage but builds the version message by
+// executing the given text/template with vars, e.g.
+// "Published by CI: {{.commit}} ({{.file}})". The page keeps its labels and
+// content appearance.
+func (api *API) UpdatePageWithTemplate(
+	page *PageInfo,
+	content string,
+	messageTemplate string,
+	vars map[string]string,
+) error {
+	tpl, err := template.New("message").
+		Option("missingkey=error").
+		Parse(messageTemplate)
+	if err != nil {
+		return karma.Format(err, "unable to parse version message template")
+	}
 
-	var labelInfo LabelInfo
-	reqFn := func() (*http.Response, error) {
-		request, err := api.rest.Res(
-			"content/"+page.ID+"/label", &labelInfo,
-		).SetQuery(map[string]string{"name": label}).Delete()
-		if err != nil {
-			return nil, err
-		}
-		return request.Raw, nil
+	var message strings.Builder
+
+	err = tpl.Execute(&message, vars)
+	if err != nil {
+		return karma.Format(err, "unable to execute version message template")
 	}
 
-	resp, err := doWithRetry(context.Background(), 5, reqFn)
+	appearance, err := api.GetEffectiveAppearance(page.ID)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	if resp.StatusCode == http.StatusTooManyRequests {
-		time.Sleep(1 * time.Second)
-		return api.DeletePageLabel(page, label)
+	return api.UpdatePage(page, content, false, message.String(), nil, appearance, "", false)
+}
+
+// GetEffectiveAppearance returns the content appearance the given page is
+// rendered with, which is "fixed" if the page doesn't set one and so follows
+// the default of Confluence.
+func (api *API) GetEffectiveAppearance(pageID string) (string, error) {
+	var appearance string
+
+	_, err := api.GetContentProperty(
+		pageID,
+		"content-appearance-published",
+		&appearance,
+	)
+	if err != nil {
+		return "", err
 	}
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return nil, newErrorStatus(resp)
+	// "default" is what older editors store for fixed-width pages
+	if appearance == "" || appearance == "default" {
+		appearance = "fixed"
 	}
 
-	return &labelInfo, nil
+	return appearance, nil
 }
 
-func (api *API) GetPageLabels(page *PageInfo, prefix string) (*LabelInfo, error) {
+// SetDefaultAppearance sets the content appearance, e.g. "full-width" or
+// "fixed", UpdatePage uses for pages of the given space when it's called
+// with an empty appearance. The space of a page is known if the page was
+// retrieved by FindPage or GetPageByID.
+func (api *API) SetDefaultAppearance(space, appearance string) {
+	api.appearanceMutex.Lock()
+	defer api.appearanceMutex.Unlock()
+
+	if api.defaultAppearances == nil {
+		api.defaultAppearances = map[string]string{}
+	}
+
+	api.defaultAppearances[space] = appearance
+}
+
+func (api *API) defaultAppearance(space string) string {
+	api.appearanceMutex.Lock()
+	defer api.appearanceMutex.Unlock()
+
+	return api.defaultAppearances[space]
+}
+
+// isPageUnchanged reports whether the given page already has the given
+// storage body, global labels, appearance and emoji. Labels and emoji are
+// not compared if they are empty, as UpdatePage leaves them untouched then.
+func (api *API) isPageUnchanged(
+	ctx context.Context,
+	pageID string,
+	storage string,
+	labels []string,
+	appearance string,
+	emoji string,
+) (bool, error) {
+	var current struct {
+		Body struct {
+			Storage struct {
+				Value string `json:"value"`
+			} `json:"storage"`
+		} `json:"body"`
+		Metadata struct {
+			Labels     LabelInfo `json:"labels"`
+			Properties map[string]struct {
+				Value json.RawMessage `json:"value"`
+			} `json:"properties"`
+		} `json:"metadata"`
+	}
 
-	var labelInfo LabelInfo
 	reqFn := func() (*http.Response, error) {
-		request, err := api.rest.Res(
-			"content/"+page.ID+"/label", &labelInfo,
-		).Get(map[string]string{"prefix": prefix})
+		request, err := api.resourceContext(
+			ctx,
+			"content/"+pageID, &current,
+		).Get(map[string]string{
+			"expand": "body.storage,metadata.labels," +
+				"metadata.properties.content-appearance-published," +
+				"metadata.properties.emoji-title-published",
+		})
 		if err != nil {
 			return nil, err
 		}
 		return request.Raw, nil
 	}
 
-	resp, err := doWithRetry(context.Background(), 5, reqFn)
+	resp, err := api.doWithRetry(ctx, api.RetryAttempts, reqFn)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
 
 	if resp.StatusCode == http.StatusTooManyRequests {
-		time.Sleep(1 * time.Second)
-		return api.GetPageLabels(page, prefix)
+		err = sleepContext(ctx, 1*time.Second)
+		if err != nil {
+			return false, err
+		}
+
+		return api.isPageUnchanged(ctx, pageID, storage, labels, appearance, emoji)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, newErrorStatus(resp)
+		return false, newErrorStatus(resp)
 	}
-	return &labelInfo, nil
+
+	if normalizeStorage(current.Body.Storage.Value) != normalizeStorage(storage) {
+		return false, nil
+	}
+
+	property := func(key string) string {
+		var value string
+		_ = json.Unmarshal(current.Metadata.Properties[key].Value, &value)
+		return value
+	}
+
+	if property("content-appearance-published") != appearance {
+		return false, nil
+	}
+
+	if emoji != "" && property("emoji-title-published") != emojiHex(emoji) {
+		return false, nil
+	}
+
+	if len(labels) > 0 {
+		currentLabels := []string{}
+		for _, label := range current.Metadata.Labels.Labels {
+			if label.Prefix == "global" {
+				currentLabels = append(currentLabels, label.Name)
+			}
+		}
+
+		if len(subtractLabels(labels, currentLabels)) > 0 ||
+			len(subtractLabels(currentLabels, labels)) > 0 {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+var (
+	reStorageVerbatim = regexp.MustCompile(`(?s)<!\[CDATA\[.*?\]\]>|<pre\b.*?</pre>`)
+	reStorageTagBreak = regexp.MustCompile(`>[ \t\r]*\n\s*<`)
+)
+
+// normalizeStorage removes the whitespace Confluence drops when it stores a
+// body, i.e. line breaks between tags, so bodies can be compared. Content of
+// CDATA sections and <pre> elements is whitespace sensitive and left alone,
+// as is any whitespace within a line.
+func normalizeStorage(storage string) string {
+	storage = strings.ReplaceAll(storage, "\r\n", "\n")
+
+	// verbatim parts are swapped for placeholder tags while line breaks are
+	// removed, NUL can't occur in storage format
+	verbatim := reStorageVerbatim.FindAllString(storage, -1)
+	storage = reStorageVerbatim.ReplaceAllString(storage, "<\x00>")
+
+	storage = reStorageTagBreak.ReplaceAllString(storage, "><")
+
+	for _, part := range verbatim {
+		storage = strings.Replace(storage, "<\x00>", part, 1)
+	}
+
+	return strings.TrimSpace(storage)
+}
+
+// DeletePage moves the given page to the trash of its space. It's not an
+// error if the page doesn't exist anymore.
+func (api *API) DeletePage(pageID string) error {
+	return api.DeletePageContext(context.Background(), pageID)
+}
+
+// DeletePageContext is like DeletePage but aborts once ctx is cancelled.
+func (api *API) DeletePageContext(ctx context.Context, pageID string) error {
+	var result interface{}
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resourceContext(
+			ctx,
+			"content/"+pageID, &result,
+		).Delete()
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(ctx, api.RetryAttempts, reqFn)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		err = sleepContext(ctx, 1*time.Second)
+		if err != nil {
+			return err
+		}
+
+		return api.DeletePageContext(ctx, pageID)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return newErrorStatus(resp)
+	}
+}
+
+// transformStorage applies StorageTransform to storage if it is set.
+func (api *API) transformStorage(storage string) string {
+	if api.StorageTransform == nil {
+		return storage
+	}
+
+	return api.StorageTransform(storage)
+}
+
+// SetParentByID moves the page with the given ID under the page with the
+// given parent ID. Unlike UpdatePage it doesn't require a PageInfo from the
+// caller: the current version is fetched internally and only the ancestors
+// are updated, leaving the page body untouched.
+func (api *API) SetParentByID(pageID, parentID string) error {
+	return api.SetParentByIDContext(context.Background(), pageID, parentID)
+}
+
+// SetParentByIDContext is like SetParentByID but aborts once ctx is
+// cancelled.
+func (api *API) SetParentByIDContext(
+	ctx context.Context,
+	pageID string,
+	parentID string,
+) error {
+	page, err := api.GetPageByIDContext(ctx, pageID)
+	if err != nil {
+		return karma.Format(err, "unable to retrieve page %q", pageID)
+	}
+
+	return api.movePage(ctx, page, parentID)
+}
+
+// MovePage makes the page with newParentID the parent of the given page. The
+// new parent has to be in the same space and must not be the page itself or
+// one of its descendants.
+func (api *API) MovePage(page *PageInfo, newParentID string) error {
+	return api.MovePageContext(context.Background(), page, newParentID)
+}
+
+// MovePageContext is like MovePage but aborts once ctx is cancelled.
+func (api *API) MovePageContext(
+	ctx context.Context,
+	page *PageInfo,
+	newParentID string,
+) error {
+	if newParentID == page.ID {
+		return karma.Describe("page", page.ID).Reason(
+			"page can't be moved below itself",
+		)
+	}
+
+	parent, err := api.GetPageByIDContext(ctx, newParentID)
+	if err != nil {
+		return karma.Format(err, "unable to retrieve page %q", newParentID)
+	}
+
+	space := page.Space.Key
+	if space == "" {
+		current, err := api.GetPageByIDContext(ctx, page.ID)
+		if err != nil {
+			return karma.Format(err, "unable to retrieve page %q", page.ID)
+		}
+
+		space = current.Space.Key
+	}
+
+	if parent.Space.Key != space {
+		return karma.
+			Describe("space", space).
+			Describe("parent space", parent.Space.Key).
+			Reason("page can't be moved to another space")
+	}
+
+	for _, ancestor := range parent.Ancestors {
+		if ancestor.ID == page.ID {
+			return karma.
+				Describe("page", page.ID).
+				Describe("parent", newParentID).
+				Reason("page can't be moved below one of its descendants")
+		}
+	}
+
+	err = api.movePage(ctx, page, newParentID)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to move page %q below page %q",
+			page.Title,
+			parent.Title,
+		)
+	}
+
+	return nil
+}
+
+func (api *API) movePage(ctx context.Context, page *PageInfo, parentID string) error {
+	payload := map[string]interface{}{
+		"id":    page.ID,
+		"type":  page.Type,
+		"title": page.Title,
+		"version": map[string]interface{}{
+			"number": page.Version.Number + 1,
+		},
+		"ancestors": []map[string]interface{}{
+			{"id": parentID},
+		},
+	}
+
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resourceContext(
+			ctx,
+			"content/"+page.ID, &map[string]interface{}{},
+		).Put(payload)
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(ctx, api.RetryAttempts, reqFn)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		err = sleepContext(ctx, 1*time.Second)
+		if err != nil {
+			return err
+		}
+
+		return api.movePage(ctx, page, parentID)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return newErrorStatus(resp)
+	}
+
+	return nil
+}
+
+// AddPageLabels adds the given global labels to the page. Empty names are
+// skipped; unlike AddLabels the labels aren't validated upfront.
+func (api *API) AddPageLabels(page *PageInfo, newLabels []string) (*LabelInfo, error) {
+	return api.AddPageLabelsContext(context.Background(), page, newLabels)
+}
+
+// AddPageLabelsContext is like AddPageLabels but aborts once ctx is cancelled.
+func (api *API) AddPageLabelsContext(ctx context.Context, page *PageInfo, newLabels []string) (*LabelInfo, error) {
+	labels := []Label{}
+	for _, name := range newLabels {
+		// e.g. an empty Label header
+		if name != "" {
+			labels = append(labels, Label{Prefix: "global", Name: name})
+		}
+	}
+
+	return api.addLabels(ctx, page, labels)
+}
+
+// AddLabels adds the given labels to the page in a single request and
+// returns all labels of the page afterwards. The prefix of a label is one of
+// "global", "my" or "team" and defaults to "global". Labels are validated
+// before anything is sent, as Confluence rejects invalid ones without saying
+// which.
+func (api *API) AddLabels(page *PageInfo, labels []Label) (*LabelInfo, error) {
+	return api.AddLabelsContext(context.Background(), page, labels)
+}
+
+// AddLabelsContext is like AddLabels but aborts once ctx is cancelled.
+func (api *API) AddLabelsContext(ctx context.Context, page *PageInfo, labels []Label) (*LabelInfo, error) {
+	labels = slices.Clone(labels)
+	for i, label := range labels {
+		if label.Prefix == "" {
+			labels[i].Prefix = "global"
+		}
+
+		err := validateLabel(labels[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return api.addLabels(ctx, page, labels)
+}
+
+func (api *API) addLabels(ctx context.Context, page *PageInfo, labels []Label) (*LabelInfo, error) {
+	payload := []map[string]interface{}{}
+	for _, label := range labels {
+		payload = append(payload, map[string]interface{}{
+			"prefix": label.Prefix,
+			"name":   label.Name,
+		})
+	}
+
+	var labelInfo LabelInfo
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resourceContext(
+			ctx,
+			"content/"+page.ID+"/label", &labelInfo,
+		).Post(payload)
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(ctx, api.RetryAttempts, reqFn)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		err = sleepContext(ctx, 1*time.Second)
+		if err != nil {
+			return nil, err
+		}
+
+		return api.addLabels(ctx, page, labels)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newErrorStatus(resp)
+	}
+
+	return &labelInfo, nil
+}
+
+// invalidLabelCharacters are the characters Confluence doesn't allow in
+// label names. Colons are accepted, as they are part of UID labels.
+const invalidLabelCharacters = "!#&()*,.;<>?@[]^"
+
+func validateLabel(label Label) error {
+	switch label.Prefix {
+	case "global", "my", "team":
+	default:
+		return karma.
+			Describe("label", label.Name).
+			Describe("prefix", label.Prefix).
+			Reason("label prefix must be one of global, my or team")
+	}
+
+	if label.Name == "" {
+		return karma.
+			Describe("prefix", label.Prefix).
+			Reason("label name is empty")
+	}
+
+	if strings.ContainsFunc(label.Name, unicode.IsSpace) {
+		return karma.
+			Describe("label", label.Name).
+			Reason("label name must not contain whitespace")
+	}
+
+	if i := strings.IndexAny(label.Name, invalidLabelCharacters); i >= 0 {
+		return karma.
+			Describe("label", label.Name).
+			Reason(fmt.Sprintf(
+				"label name must not contain %q",
+				label.Name[i:i+1],
+			))
+	}
+
+	return nil
+}
+
+func (api *API) DeletePageLabel(page *PageInfo, label string) (*LabelInfo, error) {
+	return api.DeletePageLabelContext(context.Background(), page, label)
+}
+
+// DeletePageLabelContext is like DeletePageLabel but aborts once ctx is
+// cancelled.
+func (api *API) DeletePageLabelContext(ctx context.Context, page *PageInfo, label string) (*LabelInfo, error) {
+
+	var labelInfo LabelInfo
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resourceContext(
+			ctx,
+			"content/"+page.ID+"/label", &labelInfo,
+		).SetQuery(map[string]string{"name": label}).Delete()
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(ctx, api.RetryAttempts, reqFn)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		err = sleepContext(ctx, 1*time.Second)
+		if err != nil {
+			return nil, err
+		}
+
+		return api.DeletePageLabelContext(ctx, page, label)
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return nil, newErrorStatus(resp)
+	}
+
+	return &labelInfo, nil
+}
+
+func (api *API) GetPageLabels(page *PageInfo, prefix string) (*LabelInfo, error) {
+	return api.GetPageLabelsContext(context.Background(), page, prefix)
 }
 
+// GetPageLabelsContext is like GetPageLabels but aborts once ctx is cancelled.
+func (api *API) GetPageLabelsContext(ctx context.Context, page *PageInfo, prefix string) (*LabelInfo, error) {
+
+	var labelInfo LabelInfo
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resourceContext(
+			ctx,
+			"content/"+page.ID+"/label", &labelInfo,
+		).Get(map[string]string{"prefix": prefix})
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(ctx, api.RetryAttempts, reqFn)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		err = sleepContext(ctx, 1*time.Second)
+		if err != nil {
+			return nil, err
+		}
+
+		return api.GetPageLabelsContext(ctx, page, prefix)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newErrorStatus(resp)
+	}
+	return &labelInfo, nil
+}
+
+// reAccountID matches Cloud account IDs, which are either 24 hex digits or
+// an organization prefix followed by a UUID.
+var reAccountID = regexp.MustCompile(
+	`^([0-9a-f]{24}|\d+:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})$`,
+)
+
+// GetUserByName returns the user with the given name. Email addresses and,
+// on Cloud, account IDs are looked up directly. Other names are searched by
+// full name; if the search yields several users, the one whose display name
+// equals name is returned, and an error listing the candidates otherwise.
 func (api *API) GetUserByName(name string) (*User, error) {
+	if strings.Contains(name, "@") {
+		return api.GetUserByEmail(name)
+	}
+
+	if api.isCloud() && reAccountID.MatchString(name) {
+		return api.GetUserByAccountID(name)
+	}
+
 	var response struct {
 		Results []struct {
-			User User
-		}
+			User struct {
+				User
+				DisplayName string `json:"displayName"`
+			} `json:"user"`
+		} `json:"results"`
 	}
 
 	// Try the new path first
-	_, err := api.rest.
-		Res("search").
-		Res("user", &response).
+	_, err := api.resource("search/user", &response).
 		Get(map[string]string{
 			"cql": fmt.Sprintf("user.fullname~%q", name),
 		})
@@ -856,8 +2274,7 @@ func (api *API) GetUserByName(name string) (*User, error) {
 
 	// Try old path
 	if len(response.Results) == 0 {
-		_, err := api.rest.
-			Res("search", &response).
+		_, err := api.resource("search", &response).
 			Get(map[string]string{
 				"cql": fmt.Sprintf("user.fullname~%q", name),
 			})
@@ -875,16 +2292,173 @@ func (api *API) GetUserByName(name string) (*User, error) {
 			)
 	}
 
-	return &response.Results[0].User, nil
+	if len(response.Results) == 1 {
+		user := response.Results[0].User.User
+		return &user, nil
+	}
+
+	var exact []User
+	candidates := []string{}
+	for _, result := range response.Results {
+		if result.User.DisplayName == name {
+			exact = append(exact, result.User.User)
+		}
+
+		id := result.User.AccountID
+		if id == "" {
+			id = result.User.UserKey
+		}
+
+		candidates = append(
+			candidates,
+			fmt.Sprintf("%s (%s)", result.User.DisplayName, id),
+		)
+	}
+
+	if len(exact) == 1 {
+		return &exact[0], nil
+	}
+
+	return nil, karma.
+		Describe("name", name).
+		Describe("candidates", strings.Join(candidates, ", ")).
+		Reason(
+			"several users match given name, specify the email or account ID",
+		)
+}
+
+// GetUserByAccountID returns the user with the given account ID. Confluence
+// Server and Data Center have no account IDs, so there accountID is looked up
+// as a username.
+func (api *API) GetUserByAccountID(accountID string) (*User, error) {
+	return api.GetUserByAccountIDContext(context.Background(), accountID)
+}
+
+// GetUserByAccountIDContext is like GetUserByAccountID but aborts once ctx is
+// cancelled.
+func (api *API) GetUserByAccountIDContext(ctx context.Context, accountID string) (*User, error) {
+	parameter := "accountId"
+	if !api.isCloud() {
+		parameter = "username"
+	}
+
+	var user User
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resourceContext(
+			ctx,
+			"user", &user,
+		).Get(map[string]string{parameter: accountID})
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(ctx, api.RetryAttempts, reqFn)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		err = sleepContext(ctx, 1*time.Second)
+		if err != nil {
+			return nil, err
+		}
+
+		return api.GetUserByAccountIDContext(ctx, accountID)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, karma.
+			Describe(parameter, accountID).
+			Reason("user is not found")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newErrorStatus(resp)
+	}
+
+	return &user, nil
+}
+
+// GetUserByEmail returns the user with the given email address. Users whose
+// privacy settings hide their email address can't be found this way.
+func (api *API) GetUserByEmail(email string) (*User, error) {
+	return api.GetUserByEmailContext(context.Background(), email)
+}
+
+// GetUserByEmailContext is like GetUserByEmail but aborts once ctx is
+// cancelled.
+func (api *API) GetUserByEmailContext(ctx context.Context, email string) (*User, error) {
+	var response struct {
+		Results []struct {
+			User struct {
+				User
+				Email string `json:"email"`
+			} `json:"user"`
+		} `json:"results"`
+	}
+
+	path := "search"
+	if api.isCloud() {
+		path = "search/user"
+	}
+
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resourceContext(ctx, path, &response).Get(map[string]string{
+			"cql": fmt.Sprintf("user.email = %q", email),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(ctx, api.RetryAttempts, reqFn)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		err = sleepContext(ctx, 1*time.Second)
+		if err != nil {
+			return nil, err
+		}
+
+		return api.GetUserByEmailContext(ctx, email)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newErrorStatus(resp)
+	}
+
+	hidden := false
+	for _, result := range response.Results {
+		if strings.EqualFold(result.User.Email, email) {
+			user := result.User.User
+			return &user, nil
+		}
+
+		if result.User.Email == "" {
+			hidden = true
+		}
+	}
+
+	if hidden {
+		return nil, karma.
+			Describe("email", email).
+			Reason("email address of user is hidden by their privacy settings")
+	}
+
+	return nil, karma.
+		Describe("email", email).
+		Reason("user with given email is not found")
 }
 
 func (api *API) GetCurrentUser() (*User, error) {
 	var user User
 
-	_, err := api.rest.
-		Res("user").
-		Res("current", &user).
-		Get()
+	_, err := api.resource("user/current", &user).Get()
 	if err != nil {
 		return nil, err
 	}
@@ -892,11 +2466,34 @@ func (api *API) GetCurrentUser() (*User, error) {
 	return &user, nil
 }
 
+// RestrictPageUpdatesCloud restricts editing the given page to the given
+// users and members of the given groups. Users are resolved to account IDs by
+// their full name unless they are account IDs already, group names are
+// resolved to group IDs. The user the API authenticates as is identified by
+// email on Cloud, so its username, or an empty name, stands for the current
+// user.
 func (api *API) RestrictPageUpdatesCloud(
 	page *PageInfo,
-	allowedUser string,
+	allowedUsers []string,
+	allowedGroups []string,
+) error {
+	return api.RestrictPageUpdatesCloudContext(
+		context.Background(),
+		page,
+		allowedUsers,
+		allowedGroups,
+	)
+}
+
+// RestrictPageUpdatesCloudContext is like RestrictPageUpdatesCloud but aborts
+// once ctx is cancelled.
+func (api *API) RestrictPageUpdatesCloudContext(
+	ctx context.Context,
+	page *PageInfo,
+	allowedUsers []string,
+	allowedGroups []string,
 ) error {
-	user, err := api.GetCurrentUser()
+	restrictions, err := api.cloudRestrictions(allowedUsers, allowedGroups)
 	if err != nil {
 		return err
 	}
@@ -904,37 +2501,38 @@ func (api *API) RestrictPageUpdatesCloud(
 	var result interface{}
 
 	reqFn := func() (*http.Response, error) {
-		request, err := api.rest.
-			Res("content").
-			Id(page.ID).
-			Res("restriction", &result).
-			Post([]map[string]interface{}{
-				{
-					"operation": "update",
-					"restrictions": map[string]interface{}{
-						"user": []map[string]interface{}{
-							{
-								"type":      "known",
-								"accountId": user.AccountID,
-							},
-						},
-					},
-				},
-			})
+		request, err := api.resourceContext(
+			ctx,
+			"content/"+page.ID+"/restriction", &result,
+		).Post([]map[string]interface{}{
+			{
+				"operation":    "update",
+				"restrictions": restrictions,
+			},
+		})
 		if err != nil {
 			return nil, err
 		}
 		return request.Raw, nil
 	}
 
-	resp, err := doWithRetry(context.Background(), 5, reqFn)
+	resp, err := api.doWithRetry(ctx, api.RetryAttempts, reqFn)
 	if err != nil {
 		return err
 	}
 
 	if resp.StatusCode == http.StatusTooManyRequests {
-		time.Sleep(1 * time.Second)
-		return api.RestrictPageUpdatesCloud(page, allowedUser)
+		err = sleepContext(ctx, 1*time.Second)
+		if err != nil {
+			return err
+		}
+
+		return api.RestrictPageUpdatesCloudContext(
+			ctx,
+			page,
+			allowedUsers,
+			allowedGroups,
+		)
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -944,26 +2542,118 @@ func (api *API) RestrictPageUpdatesCloud(
 	return nil
 }
 
+// cloudRestrictions returns the restrictions part of a Cloud restriction
+// payload allowing the given users and groups. Entries are only present for
+// subjects that are given.
+func (api *API) cloudRestrictions(
+	users []string,
+	groups []string,
+) (map[string]interface{}, error) {
+	restrictions := map[string]interface{}{}
+
+	if len(users) > 0 {
+		entries := []map[string]interface{}{}
+		for _, name := range users {
+			accountID, err := api.resolveAccountID(name)
+			if err != nil {
+				return nil, karma.Format(err, "unable to resolve user %q", name)
+			}
+
+			entries = append(entries, map[string]interface{}{
+				"type":      "known",
+				"accountId": accountID,
+			})
+		}
+
+		restrictions["user"] = entries
+	}
+
+	if len(groups) > 0 {
+		entries := []map[string]interface{}{}
+		for _, name := range groups {
+			id, err := api.getGroupID(name)
+			if err != nil {
+				return nil, karma.Format(err, "unable to resolve group %q", name)
+			}
+
+			entries = append(entries, map[string]interface{}{
+				"type": "group",
+				"name": name,
+				"id":   id,
+			})
+		}
+
+		restrictions["group"] = entries
+	}
+
+	return restrictions, nil
+}
+
+// RestrictPageUpdatesServer restricts editing the given page to the users
+// with the given usernames and members of the given groups.
 func (api *API) RestrictPageUpdatesServer(
 	page *PageInfo,
-	allowedUser string,
+	allowedUsers []string,
+	allowedGroups []string,
 ) error {
-	var (
-		err    error
-		result interface{}
+	return api.RestrictPageUpdatesServerContext(
+		context.Background(),
+		page,
+		allowedUsers,
+		allowedGroups,
 	)
+}
+
+// RestrictPageUpdatesServerContext is like RestrictPageUpdatesServer but aborts
+// once ctx is cancelled.
+func (api *API) RestrictPageUpdatesServerContext(
+	ctx context.Context,
+	page *PageInfo,
+	allowedUsers []string,
+	allowedGroups []string,
+) error {
+	return api.setContentPermissions(
+		ctx,
+		page.ID,
+		"Edit",
+		allowedUsers,
+		allowedGroups,
+	)
+}
+
+// setContentPermissions replaces the users and groups the given json-rpc
+// permission type, "View" or "Edit", of a page is restricted to on Server.
+// The permission isn't restricted anymore if neither are given.
+func (api *API) setContentPermissions(
+	ctx context.Context,
+	pageID string,
+	permission string,
+	users []string,
+	groups []string,
+) error {
+	var result interface{}
+
+	entries := []map[string]interface{}{}
+	for _, name := range users {
+		entries = append(entries, map[string]interface{}{
+			"userName": name,
+		})
+	}
+
+	for _, name := range groups {
+		entries = append(entries, map[string]interface{}{
+			"groupName": name,
+		})
+	}
 
 	reqFn := func() (*http.Response, error) {
-		request, err := api.json.Res(
+		request, err := api.rpcContext(
+			ctx,
 			"setContentPermissions", &result,
 		).Post([]interface{}{
-			page.ID,
-			"Edit",
-			[]map[string]interface{}{
-				{
-					"userName": allowedUser,
-				},
-			},
+			pageID,
+			permission,
+			entries,
 		})
 		if err != nil {
 			return nil, err
@@ -971,14 +2661,18 @@ func (api *API) RestrictPageUpdatesServer(
 		return request.Raw, nil
 	}
 
-	resp, err := doWithRetry(context.Background(), 5, reqFn)
+	resp, err := api.doWithRetry(ctx, api.RetryAttempts, reqFn)
 	if err != nil {
 		return err
 	}
 
 	if resp.StatusCode == http.StatusTooManyRequests {
-		time.Sleep(1 * time.Second)
-		return api.RestrictPageUpdatesServer(page, allowedUser)
+		err = sleepContext(ctx, 1*time.Second)
+		if err != nil {
+			return err
+		}
+
+		return api.setContentPermissions(ctx, pageID, permission, users, groups)
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -995,32 +2689,217 @@ func (api *API) RestrictPageUpdatesServer(
 	return nil
 }
 
+// RestrictPageUpdates restricts editing the given page to a single user. The
+// deployment, if given, overrides the one of the API for this call.
 func (api *API) RestrictPageUpdates(
 	page *PageInfo,
 	allowedUser string,
+	deployment ...Deployment,
 ) error {
 	var err error
 
-	if strings.HasSuffix(api.rest.Api.BaseUrl.Host, "jira.com") || strings.HasSuffix(api.rest.Api.BaseUrl.Host, "atlassian.net") {
-		err = api.RestrictPageUpdatesCloud(page, allowedUser)
+	if api.isCloudFor(deployment) {
+		err = api.RestrictPageUpdatesCloud(page, []string{allowedUser}, nil)
 	} else {
-		err = api.RestrictPageUpdatesServer(page, allowedUser)
+		err = api.RestrictPageUpdatesServer(page, []string{allowedUser}, nil)
 	}
 
 	return err
 }
 
+// resolveAccountID returns the account ID of the user with the given name on
+// Cloud. Account IDs are returned as they are. The username of the API and an
+// empty name stand for the current user.
+func (api *API) resolveAccountID(name string) (string, error) {
+	if reAccountID.MatchString(name) {
+		return name, nil
+	}
+
+	auth := api.rest.Api.BasicAuth
+	if name == "" || (auth != nil && strings.EqualFold(auth.Username, name)) {
+		user, err := api.GetCurrentUser()
+		if err != nil {
+			return "", err
+		}
+
+		return user.AccountID, nil
+	}
+
+	user, err := api.GetUserByName(name)
+	if err != nil {
+		return "", err
+	}
+
+	return user.AccountID, nil
+}
+
+// Deployment is the kind of Confluence instance an API talks to, which
+// decides between the Cloud and the Server code paths of some calls.
+type Deployment int
+
+const (
+	// DeploymentAuto detects the deployment from the hostname of the
+	// instance.
+	DeploymentAuto Deployment = iota
+	DeploymentCloud
+	DeploymentServer
+)
+
+// isCloud reports whether the API points to an Atlassian Cloud instance.
+// Unless API.Deployment says otherwise it's detected by its hostname.
+func (api *API) isCloud() bool {
+	switch api.Deployment {
+	case DeploymentCloud:
+		return true
+	case DeploymentServer:
+		return false
+	}
+
+	host := api.rest.Api.BaseUrl.Host
+
+	return strings.HasSuffix(host, "jira.com") ||
+		strings.HasSuffix(host, "atlassian.net")
+}
+
+// isCloudFor is like isCloud, but honors the deployment passed to a call,
+// if any.
+func (api *API) isCloudFor(deployment []Deployment) bool {
+	if len(deployment) > 0 && deployment[0] != DeploymentAuto {
+		return deployment[0] == DeploymentCloud
+	}
+
+	return api.isCloud()
+}
+
+// ReorderChild moves the given page relative to the target page on
+// Confluence Server: "above" or "below" make it a sibling placed right before
+// or after the target, "append" makes it the last child of the target.
+func (api *API) ReorderChild(pageID, position, targetID string) error {
+	switch position {
+	case "above", "below", "append":
+	default:
+		return karma.Describe("position", position).Reason(
+			"position must be one of above, below or append",
+		)
+	}
+
+	var result interface{}
+
+	err := api.CallJSONRPC(
+		"movePage",
+		[]interface{}{pageID, targetID, position},
+		&result,
+	)
+	if err != nil {
+		return karma.Format(err, "unable to move page %q", pageID)
+	}
+
+	if success, ok := result.(bool); !ok || !success {
+		return fmt.Errorf(
+			"'true' response expected, but '%v' encountered",
+			result,
+		)
+	}
+
+	return nil
+}
+
+// CallJSONRPC invokes the given method of the JSON-RPC API of Confluence
+// Server with params and decodes the result into out, which may be nil. It
+// gives access to the Server operations mark doesn't wrap, e.g.
+// "movePageToTopOfChildren".
+func (api *API) CallJSONRPC(
+	method string,
+	params []interface{},
+	out interface{},
+) error {
+	return api.CallJSONRPCContext(
+		context.Background(),
+		method,
+		params,
+		out,
+	)
+}
+
+// CallJSONRPCContext is like CallJSONRPC but aborts once ctx is cancelled.
+func (api *API) CallJSONRPCContext(
+	ctx context.Context,
+	method string,
+	params []interface{},
+	out interface{},
+) error {
+	if out == nil {
+		out = &json.RawMessage{}
+	}
+
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	reqFn := func() (*http.Response, error) {
+		request, err := api.rpcContext(ctx, method, out).Post(params)
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(ctx, api.RetryAttempts, reqFn)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		err = sleepContext(ctx, 1*time.Second)
+		if err != nil {
+			return err
+		}
+
+		return api.CallJSONRPCContext(ctx, method, params, out)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return karma.Format(
+			newErrorStatus(resp),
+			"json-rpc method %q failed",
+			method,
+		)
+	}
+
+	return nil
+}
+
+// APIError is the error of a request that Confluence answered with a non-2xx
+// status.
+type APIError struct {
+	StatusCode int
+	Status     string
+	Body       []byte
+}
+
+func (err *APIError) Error() string {
+	switch err.StatusCode {
+	case http.StatusUnauthorized:
+		return "the Confluence API returned 401 (Unauthorized)"
+	case http.StatusNotFound:
+		return "the Confluence API returned 404 (Not Found)"
+	default:
+		return fmt.Sprintf(
+			"the Confluence API returned %s: %s",
+			err.Status,
+			err.Body,
+		)
+	}
+}
+
 // newErrorStatus converts a non-2xx response into a useful error.
 func newErrorStatus(resp *http.Response) error {
 	defer resp.Body.Close()
 	body, _ := io.ReadAll(resp.Body)
 
-	switch resp.StatusCode {
-	case http.StatusUnauthorized:
-		return errors.New("the Confluence API returned 401 (Unauthorized)")
-	case http.StatusNotFound:
-		return errors.New("the Confluence API returned 404 (Not Found)")
-	default:
-		return fmt.Errorf("the Confluence API returned %s: %s", resp.Status, body)
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       body,
 	}
 }
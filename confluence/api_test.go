@@ -0,0 +1,805 @@
+package confluence
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestAPI starts a fake Confluence server backed by handler and returns an
+// API pointed at it. The server is shut down when the test finishes.
+func newTestAPI(t *testing.T, handler http.HandlerFunc) *API {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return NewAPI(server.URL, "user", "password")
+}
+
+// newCloudTestAPI is like newTestAPI but returns an API that considers itself
+// connected to Atlassian Cloud. Requests are still served by handler.
+func newCloudTestAPI(t *testing.T, handler http.HandlerFunc) *API {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := &http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			r.URL.Scheme = "http"
+			r.URL.Host = server.Listener.Addr().String()
+
+			return http.DefaultTransport.RoundTrip(r)
+		}),
+	}
+
+	api := NewAPI("https://example.atlassian.net/wiki", "user", "password")
+	api.rest.Api.Client = client
+	api.json.Api.Client = client
+
+	return api
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return fn(r)
+}
+
+func TestSetParentByID(t *testing.T) {
+	var payload map[string]interface{}
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/content/42", r.URL.Path)
+
+		switch r.Method {
+		case http.MethodGet:
+			_, _ = io.WriteString(w, `{
+				"id": "42",
+				"type": "page",
+				"title": "Child",
+				"version": {"number": 3},
+				"ancestors": [{"id": "1", "title": "Old Parent"}]
+			}`)
+
+		case http.MethodPut:
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			_, _ = io.WriteString(w, `{}`)
+		}
+	})
+
+	assert.NoError(t, api.SetParentByID("42", "7"))
+
+	assert.Equal(t, map[string]interface{}{
+		"id":        "42",
+		"type":      "page",
+		"title":     "Child",
+		"version":   map[string]interface{}{"number": 4.0},
+		"ancestors": []interface{}{map[string]interface{}{"id": "7"}},
+	}, payload)
+}
+
+func TestTitleTooLong(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request: %s %s", r.Method, r.URL)
+	})
+
+	title := strings.Repeat("ä", 300)
+
+	_, err := api.CreatePage("DOC", "page", nil, title, "")
+
+	var tooLong ErrTitleTooLong
+	assert.ErrorAs(t, err, &tooLong)
+	assert.Equal(t, 300, tooLong.Length)
+
+	page := &PageInfo{ID: "1", Type: "page", Title: title}
+	err = api.UpdatePage(page, "", false, "", nil, "full-width", "", false)
+	assert.ErrorAs(t, err, &tooLong)
+
+	assert.NoError(t, validateTitle(strings.Repeat("ä", MaxTitleLength)))
+}
+
+func TestLastRateLimit(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "100")
+		w.Header().Set("X-RateLimit-Remaining", "7")
+		w.Header().Set("X-RateLimit-Reset", "2026-10-15T12:00:00Z")
+		w.Header().Set("X-RateLimit-NearLimit", "true")
+
+		_, _ = io.WriteString(w, `{"id": "1"}`)
+	})
+
+	assert.Equal(t, RateLimitInfo{}, api.LastRateLimit())
+
+	_, err := api.GetPageByID("1")
+	assert.NoError(t, err)
+
+	assert.Equal(t, RateLimitInfo{
+		Limit:     100,
+		Remaining: 7,
+		Reset:     time.Date(2026, time.October, 15, 12, 0, 0, 0, time.UTC),
+		NearLimit: true,
+	}, api.LastRateLimit())
+}
+
+func TestCreatePageCreatedDate(t *testing.T) {
+	var payload map[string]interface{}
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		payload = nil
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		_, _ = io.WriteString(w, `{"id": "1"}`)
+	})
+
+	created := time.Date(2015, time.June, 1, 9, 30, 0, 0, time.UTC)
+	_, err := api.CreatePageWithDate("DOC", "page", nil, "Old", "", created)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"createdDate": "2015-06-01T09:30:00Z",
+	}, payload["history"])
+
+	_, err = api.CreatePage("DOC", "page", nil, "New", "")
+	assert.NoError(t, err)
+	assert.NotContains(t, payload, "history")
+}
+
+func TestStorageTransform(t *testing.T) {
+	var bodies []string
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Body struct {
+				Storage struct {
+					Value string `json:"value"`
+				} `json:"storage"`
+			} `json:"body"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		bodies = append(bodies, payload.Body.Storage.Value)
+
+		_, _ = io.WriteString(w, `{"id": "1"}`)
+	})
+
+	_, err := api.CreatePage("DOC", "page", nil, "Plain", "<p>a</p>")
+	assert.NoError(t, err)
+
+	api.StorageTransform = func(storage string) string {
+		return "<div>" + storage + "</div>"
+	}
+
+	_, err = api.CreatePage("DOC", "page", nil, "Wrapped", "<p>b</p>")
+	assert.NoError(t, err)
+
+	err = api.UpdatePage(&PageInfo{ID: "1", Title: "Wrapped"}, "<p>c</p>", false, "", nil, "", "", false)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"<p>a</p>",
+		"<div><p>b</p></div>",
+		"<div><p>c</p></div>",
+	}, bodies)
+}
+
+func TestUpdatePageWithTemplate(t *testing.T) {
+	var message, appearance string
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var payload struct {
+			Version struct {
+				Message string `json:"message"`
+			} `json:"version"`
+			Metadata struct {
+				Properties map[string]struct {
+					Value string `json:"value"`
+				} `json:"properties"`
+			} `json:"metadata"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		message = payload.Version.Message
+		appearance = payload.Metadata.Properties["content-appearance-published"].Value
+
+		_, _ = io.WriteString(w, `{}`)
+	})
+
+	page := &PageInfo{ID: "1", Title: "Page"}
+
+	err := api.UpdatePageWithTemplate(
+		page,
+		"<p>body</p>",
+		"Published by CI: {{.commit}} ({{.file}})",
+		map[string]string{"commit": "abc123", "file": "docs/index.md"},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, "Published by CI: abc123 (docs/index.md)", message)
+
+	// a page without an appearance is rendered fixed and stays so
+	assert.Equal(t, "fixed", appearance)
+
+	err = api.UpdatePageWithTemplate(page, "", "{{.missing}}", nil)
+	assert.Error(t, err)
+}
+
+func TestSetDefaultAppearance(t *testing.T) {
+	var appearance interface{}
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Metadata struct {
+				Properties map[string]struct {
+					Value interface{} `json:"value"`
+				} `json:"properties"`
+			} `json:"metadata"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		appearance = payload.Metadata.Properties["content-appearance-published"].Value
+
+		_, _ = io.WriteString(w, `{}`)
+	})
+
+	api.SetDefaultAppearance("DOC", "fixed")
+
+	page := &PageInfo{ID: "1", Title: "Page"}
+	page.Space.Key = "DOC"
+
+	assert.NoError(t, api.UpdatePage(page, "", false, "", nil, "", "", false))
+	assert.Equal(t, "fixed", appearance)
+
+	assert.NoError(t, api.UpdatePage(page, "", false, "", nil, "full-width", "", false))
+	assert.Equal(t, "full-width", appearance)
+
+	page.Space.Key = "OPS"
+
+	assert.NoError(t, api.UpdatePage(page, "", false, "", nil, "", "", false))
+	assert.Equal(t, "", appearance)
+}
+
+func TestNewAPIContextPath(t *testing.T) {
+	var paths []string
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			paths = append(paths, r.URL.Path)
+
+			_, _ = io.WriteString(w, `{"key": "DOC"}`)
+		},
+	))
+	t.Cleanup(server.Close)
+
+	for _, test := range []struct {
+		baseURL     string
+		contextPath string
+	}{
+		{server.URL, ""},
+		{server.URL + "/", ""},
+		{server.URL + "/confluence", "/confluence"},
+		{server.URL + "/confluence/", "/confluence"},
+		{server.URL + "/tools/confluence//", "/tools/confluence"},
+	} {
+		paths = nil
+
+		api := NewAPI(test.baseURL, "user", "password")
+		assert.Equal(t, server.URL+test.contextPath, api.BaseURL, test.baseURL)
+
+		_, err := api.GetSpace("DOC")
+		assert.NoError(t, err, test.baseURL)
+
+		_, err = api.streamJSON(t.Context(), http.MethodPost, "content/", nil, &struct{}{})
+		assert.NoError(t, err, test.baseURL)
+
+		assert.Equal(
+			t,
+			[]string{
+				test.contextPath + "/rest/api/space/DOC",
+				test.contextPath + "/rest/api/content/",
+			},
+			paths,
+			test.baseURL,
+		)
+
+		instance := api.instanceURL("/plugins/macrobrowser/browse-macros.action")
+		assert.Equal(
+			t,
+			server.URL+test.contextPath+"/plugins/macrobrowser/browse-macros.action",
+			instance.String(),
+		)
+	}
+}
+
+func TestGetUserByEmail(t *testing.T) {
+	api := newCloudTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/wiki/rest/api/search/user", r.URL.Path)
+		assert.Equal(t, `user.email = "jane@example.com"`, r.URL.Query().Get("cql"))
+
+		_, _ = io.WriteString(w, `{"results": [
+			{"user": {"accountId": "hidden", "email": ""}},
+			{"user": {"accountId": "557058:jane", "email": "Jane@example.com"}}
+		]}`)
+	})
+
+	user, err := api.GetUserByEmail("jane@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "557058:jane", user.AccountID)
+}
+
+func TestGetUserByName(t *testing.T) {
+	results := `{"results": [
+		{"user": {"accountId": "557058:ann", "displayName": "Ann Smith"}},
+		{"user": {"accountId": "557058:anna", "displayName": "Anna Smith"}}
+	]}`
+
+	api := newCloudTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/wiki/rest/api/search/user":
+			_, _ = io.WriteString(w, results)
+		case "/wiki/rest/api/user":
+			assert.Equal(
+				t,
+				"5b10a2844c20165700ede21a",
+				r.URL.Query().Get("accountId"),
+			)
+
+			_, _ = io.WriteString(w, `{"accountId": "5b10a2844c20165700ede21a"}`)
+		default:
+			t.Errorf("unexpected request: %s", r.URL.Path)
+		}
+	})
+
+	user, err := api.GetUserByName("Anna Smith")
+	assert.NoError(t, err)
+	assert.Equal(t, "557058:anna", user.AccountID)
+
+	_, err = api.GetUserByName("Smith")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Ann Smith (557058:ann)")
+	assert.Contains(t, err.Error(), "Anna Smith (557058:anna)")
+
+	user, err = api.GetUserByName("5b10a2844c20165700ede21a")
+	assert.NoError(t, err)
+	assert.Equal(t, "5b10a2844c20165700ede21a", user.AccountID)
+}
+
+func TestCallJSONRPC(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(
+			t,
+			"/rpc/json-rpc/confluenceservice-v2/getPageSummary",
+			r.URL.Path,
+		)
+
+		var params []interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&params))
+		assert.Equal(t, []interface{}{"42"}, params)
+
+		_, _ = io.WriteString(w, `{"id": "42", "title": "Page"}`)
+	})
+
+	var summary struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+	}
+
+	err := api.CallJSONRPC("getPageSummary", []interface{}{"42"}, &summary)
+	assert.NoError(t, err)
+	assert.Equal(t, "Page", summary.Title)
+}
+
+func TestGetPageByIDContextCancelledDuringBackOff(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+
+	_, err := api.GetPageByIDContext(ctx, "1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(started), time.Second)
+}
+
+func TestReorderChild(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rpc/json-rpc/confluenceservice-v2/movePage", r.URL.Path)
+
+		var params []interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&params))
+		assert.Equal(t, []interface{}{"2", "1", "above"}, params)
+
+		_, _ = io.WriteString(w, `true`)
+	})
+
+	assert.NoError(t, api.ReorderChild("2", "above", "1"))
+	assert.Error(t, api.ReorderChild("2", "first", "1"))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, time.October, 15, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 30*time.Second, parseRetryAfter("30", now))
+	assert.Equal(
+		t,
+		90*time.Second,
+		parseRetryAfter("Thu, 15 Oct 2026 12:01:30 GMT", now),
+	)
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+}
+
+func TestDoWithRetryHonorsRetryAfter(t *testing.T) {
+	var times []time.Time
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		times = append(times, time.Now())
+
+		if len(times) == 1 {
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		_, _ = io.WriteString(w, `{"id": "1"}`)
+	})
+
+	_, err := api.GetPageByID("1")
+	assert.NoError(t, err)
+
+	assert.Len(t, times, 2)
+	assert.GreaterOrEqual(t, times[1].Sub(times[0]), 2*time.Second)
+}
+
+func TestRetryAfterMaintenance(t *testing.T) {
+	api := NewAPI("http://localhost", "user", "password")
+	now := time.Now()
+
+	response := func(status int, retryAfter string) *http.Response {
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{"Retry-After": []string{retryAfter}},
+		}
+	}
+
+	assert.Equal(
+		t,
+		30*time.Second,
+		api.retryAfter(response(http.StatusServiceUnavailable, "30"), now),
+	)
+
+	api.MaxMaintenanceWait = 10 * time.Second
+	assert.Equal(
+		t,
+		10*time.Second,
+		api.retryAfter(response(http.StatusServiceUnavailable, "30"), now),
+	)
+	assert.Equal(
+		t,
+		30*time.Second,
+		api.retryAfter(response(http.StatusTooManyRequests, "30"), now),
+	)
+}
+
+func TestDoWithRetryMaintenance(t *testing.T) {
+	var times []time.Time
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		times = append(times, time.Now())
+
+		if len(times) == 1 {
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		_, _ = io.WriteString(w, `{"id": "1"}`)
+	})
+
+	_, err := api.GetPageByID("1")
+	assert.NoError(t, err)
+
+	assert.Len(t, times, 2)
+	assert.GreaterOrEqual(t, times[1].Sub(times[0]), 2*time.Second)
+
+	api.RetryAttempts = 1
+	times = nil
+
+	_, err = api.GetPageByID("1")
+	assert.ErrorContains(t, err, "503")
+}
+
+func TestDeletePage(t *testing.T) {
+	status := http.StatusNoContent
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodDelete, r.Method)
+		assert.Equal(t, "/rest/api/content/42", r.URL.Path)
+
+		w.WriteHeader(status)
+	})
+
+	assert.NoError(t, api.DeletePage("42"))
+
+	status = http.StatusNotFound
+	assert.NoError(t, api.DeletePage("42"))
+
+	status = http.StatusForbidden
+	assert.Error(t, api.DeletePage("42"))
+}
+
+func TestGetEffectiveAppearance(t *testing.T) {
+	store := newPropertyStore()
+	api := newTestAPI(t, store.ServeHTTP)
+
+	appearance, err := api.GetEffectiveAppearance("1")
+	assert.NoError(t, err)
+	assert.Equal(t, "fixed", appearance)
+
+	store.properties["content-appearance-published"] = contentProperty{
+		Key:   "content-appearance-published",
+		Value: json.RawMessage(`"full-width"`),
+	}
+
+	appearance, err = api.GetEffectiveAppearance("1")
+	assert.NoError(t, err)
+	assert.Equal(t, "full-width", appearance)
+}
+
+func TestMovePage(t *testing.T) {
+	var payload map[string]interface{}
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rest/api/content/7":
+			_, _ = io.WriteString(w, `{
+				"id": "7",
+				"title": "New Parent",
+				"space": {"key": "DOC"},
+				"ancestors": [{"id": "1", "title": "Home"}]
+			}`)
+
+		case r.URL.Path == "/rest/api/content/8":
+			_, _ = io.WriteString(w, `{
+				"id": "8",
+				"title": "Grandchild",
+				"space": {"key": "DOC"},
+				"ancestors": [{"id": "1", "title": "Home"}, {"id": "42", "title": "Child"}]
+			}`)
+
+		case r.URL.Path == "/rest/api/content/9":
+			_, _ = io.WriteString(w, `{"id": "9", "title": "Other", "space": {"key": "OPS"}}`)
+
+		case r.URL.Path == "/rest/api/content/42" && r.Method == http.MethodPut:
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			_, _ = io.WriteString(w, `{}`)
+
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL)
+		}
+	})
+
+	page := &PageInfo{ID: "42", Type: "page", Title: "Child"}
+	page.Version.Number = 3
+	page.Space.Key = "DOC"
+
+	assert.Error(t, api.MovePage(page, "42"))
+	assert.Error(t, api.MovePage(page, "8"))
+	assert.Error(t, api.MovePage(page, "9"))
+	assert.Nil(t, payload)
+
+	assert.NoError(t, api.MovePage(page, "7"))
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"id": "7"},
+	}, payload["ancestors"])
+	assert.Equal(t, map[string]interface{}{"number": 4.0}, payload["version"])
+}
+
+func TestUpdatePageSkipIfUnchanged(t *testing.T) {
+	updates := 0
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			updates++
+			_, _ = io.WriteString(w, `{}`)
+			return
+		}
+
+		_, _ = io.WriteString(w, `{
+			"body": {"storage": {"value": "<p>a</p>\n<p>b c</p>"}},
+			"metadata": {
+				"labels": {"results": [{"prefix": "global", "name": "docs"}]},
+				"properties": {
+					"content-appearance-published": {"value": "full-width"}
+				}
+			}
+		}`)
+	})
+
+	page := &PageInfo{ID: "1", Title: "Page"}
+
+	err := api.UpdatePage(page, "<p>a</p><p>b c</p>", false, "", []string{"docs"}, "full-width", "", true)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, updates)
+
+	err = api.UpdatePage(page, "<p>a</p><p>b c</p>", false, "", []string{"docs"}, "fixed", "", true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, updates)
+
+	err = api.UpdatePage(page, "<p>changed</p>", false, "", nil, "full-width", "", true)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, updates)
+
+	err = api.UpdatePage(page, "<p>a</p><p>b c</p>", false, "", nil, "full-width", "", false)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, updates)
+}
+
+func TestNormalizeStorage(t *testing.T) {
+	assert.Equal(
+		t,
+		normalizeStorage("<p>a</p>\n<p>b</p>"),
+		normalizeStorage("<p>a</p>\r\n  <p>b</p>\n"),
+	)
+
+	// whitespace within a line is kept
+	assert.NotEqual(
+		t,
+		normalizeStorage("<p><strong>a</strong> <em>b</em></p>"),
+		normalizeStorage("<p><strong>a</strong><em>b</em></p>"),
+	)
+	assert.NotEqual(
+		t,
+		normalizeStorage("<p>b c</p>"),
+		normalizeStorage("<p>b  c</p>"),
+	)
+
+	// as is the content of code blocks
+	assert.NotEqual(
+		t,
+		normalizeStorage("<ac:plain-text-body><![CDATA[if a {\n  b()\n}]]></ac:plain-text-body>"),
+		normalizeStorage("<ac:plain-text-body><![CDATA[if a {\n    b()\n}]]></ac:plain-text-body>"),
+	)
+	assert.NotEqual(
+		t,
+		normalizeStorage("<pre><b>a</b>\n<b>b</b></pre>"),
+		normalizeStorage("<pre><b>a</b><b>b</b></pre>"),
+	)
+	assert.Equal(
+		t,
+		"<p>a</p><ac:plain-text-body><![CDATA[x\n<y>\n</y>]]></ac:plain-text-body>",
+		normalizeStorage("<p>a</p>\n<ac:plain-text-body><![CDATA[x\n<y>\n</y>]]></ac:plain-text-body>\n"),
+	)
+}
+
+func TestNewAPIWithClient(t *testing.T) {
+	var paths []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request bypassed the client: %s", r.URL)
+	}))
+	t.Cleanup(server.Close)
+
+	client := &http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			paths = append(paths, r.URL.Path)
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader(`true`)),
+				Request:    r,
+			}, nil
+		}),
+	}
+
+	api := NewAPIWithClient(server.URL, "user", "password", client)
+
+	var result bool
+	assert.NoError(t, api.CallJSONRPC("setContentPermissions", []interface{}{}, &result))
+	assert.True(t, result)
+
+	_, _ = api.GetPageByID("42")
+
+	assert.Equal(t, []string{
+		"/rpc/json-rpc/confluenceservice-v2/setContentPermissions",
+		"/rest/api/content/42",
+	}, paths)
+}
+
+func TestNewAPIWithToken(t *testing.T) {
+	var headers []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _, basic := r.BasicAuth()
+		assert.False(t, basic)
+
+		headers = append(headers, r.Header.Get("Authorization"))
+
+		_, _ = io.WriteString(w, `true`)
+	}))
+	t.Cleanup(server.Close)
+
+	api := NewAPIWithToken(server.URL, "secret")
+
+	var result bool
+	assert.NoError(t, api.CallJSONRPC("setContentPermissions", []interface{}{}, &result))
+
+	_, _ = api.GetPageByID("42")
+
+	assert.Equal(t, []string{"Bearer secret", "Bearer secret"}, headers)
+}
+
+func TestAPIError(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		_, _ = io.WriteString(w, `{"message": "version conflict"}`)
+	})
+
+	_, err := api.GetPageByID("1")
+
+	var apiErr *APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, http.StatusConflict, apiErr.StatusCode)
+		assert.Equal(t, `{"message": "version conflict"}`, string(apiErr.Body))
+	}
+
+	assert.EqualError(
+		t,
+		err,
+		`the Confluence API returned 409 Conflict: {"message": "version conflict"}`,
+	)
+}
+
+func TestUpdatePageConflict(t *testing.T) {
+	var versions []float64
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_, _ = io.WriteString(w, `{"id": "42", "version": {"number": 7}}`)
+			return
+		}
+
+		var payload struct {
+			Version struct {
+				Number float64 `json:"number"`
+			} `json:"version"`
+		}
+
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		versions = append(versions, payload.Version.Number)
+
+		if payload.Version.Number < 8 {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = io.WriteString(w, `{"message": "version conflict"}`)
+			return
+		}
+
+		_, _ = io.WriteString(w, `{}`)
+	})
+
+	page := &PageInfo{ID: "42", Title: "Page", Type: "page"}
+	page.Version.Number = 5
+
+	err := api.UpdatePage(page, "<p>a</p>", false, "", nil, "fixed", "", false)
+	assert.ErrorContains(t, err, `page "Page" was modified concurrently`)
+	assert.Equal(t, []float64{6}, versions)
+
+	api.RetryOnConflict = true
+	versions = nil
+
+	err = api.UpdatePage(page, "<p>a</p>", false, "", nil, "fixed", "", false)
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{6, 8}, versions)
+}
@@ -0,0 +1,948 @@
+package confluence
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/reconquest/karma-go"
+	"github.com/reconquest/pkg/log"
+)
+
+// attachmentChecksumPrefix precedes the checksum of the data of an attachment
+// in its comment. It's the format earlier versions of mark wrote.
+const attachmentChecksumPrefix = "mark:checksum: "
+
+// attachmentMetaVersion is the version of the AttachmentMeta format written
+// by FormatAttachmentComment.
+const attachmentMetaVersion = 1
+
+// AttachmentMetaPrefix precedes the JSON encoded AttachmentMeta in the
+// comment of an attachment, which tells it apart from comments written by
+// humans.
+const AttachmentMetaPrefix = "mark:meta: "
+
+// AttachmentMeta is the structured data mark stores in the comment of an
+// attachment: where the attachment came from and the checksum of its data.
+type AttachmentMeta struct {
+	Version  int    `json:"version"`
+	Source   string `json:"source,omitempty"`
+	Checksum string `json:"checksum,omitempty"`
+}
+
+type attachmentContent struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Title   string `json:"title"`
+	Version struct {
+		Number int64 `json:"number"`
+	} `json:"version"`
+	Metadata struct {
+		Comment string `json:"comment"`
+	} `json:"metadata"`
+}
+
+// SetAttachmentMeta stores meta as JSON in the comment of the given
+// attachment without uploading new data.
+func (api *API) SetAttachmentMeta(
+	pageID string,
+	attachmentID string,
+	meta AttachmentMeta,
+) error {
+	attachment, err := api.getAttachment(attachmentID)
+	if err != nil {
+		return err
+	}
+
+	comment := FormatAttachmentComment(meta)
+
+	payload := map[string]interface{}{
+		"id":    attachment.ID,
+		"type":  attachment.Type,
+		"title": attachment.Title,
+		"version": map[string]interface{}{
+			"number": attachment.Version.Number + 1,
+		},
+		"metadata": map[string]interface{}{
+			"comment": comment,
+		},
+	}
+
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resource(
+			"content/"+pageID+"/child/attachment/"+attachmentID,
+			&map[string]interface{}{},
+		).Put(payload)
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		time.Sleep(1 * time.Second)
+		return api.SetAttachmentMeta(pageID, attachmentID, meta)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return newErrorStatus(resp)
+	}
+
+	return nil
+}
+
+// GetAttachmentMeta reads the structured data stored in the comment of the
+// given attachment by SetAttachmentMeta.
+func (api *API) GetAttachmentMeta(
+	pageID string,
+	attachmentID string,
+) (AttachmentMeta, error) {
+	attachment, err := api.getAttachment(attachmentID)
+	if err != nil {
+		return AttachmentMeta{}, err
+	}
+
+	return parseAttachmentMeta(attachment.Metadata.Comment)
+}
+
+func (api *API) getAttachment(attachmentID string) (*attachmentContent, error) {
+	var attachment attachmentContent
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resource(
+			"content/"+attachmentID, &attachment,
+		).Get(map[string]string{"expand": "version,metadata"})
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		time.Sleep(1 * time.Second)
+		return api.getAttachment(attachmentID)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newErrorStatus(resp)
+	}
+
+	return &attachment, nil
+}
+
+func parseAttachmentMeta(comment string) (AttachmentMeta, error) {
+	meta, ok := ParseAttachmentComment(comment)
+	if !ok {
+		return AttachmentMeta{}, karma.Describe("comment", comment).Reason(
+			"attachment comment holds no metadata",
+		)
+	}
+
+	return meta, nil
+}
+
+// FormatAttachmentComment returns the attachment comment storing meta, which
+// can be passed to CreateAttachment and UpdateAttachment.
+func FormatAttachmentComment(meta AttachmentMeta) string {
+	meta.Version = attachmentMetaVersion
+
+	// marshalling a struct of strings and ints can't fail
+	data, _ := json.Marshal(meta)
+
+	return AttachmentMetaPrefix + string(data)
+}
+
+// ParseAttachmentComment reads the AttachmentMeta stored in the comment of an
+// attachment, e.g. AttachmentInfo.Metadata.Comment. Besides the format of
+// FormatAttachmentComment it reads the plain checksums and unprefixed JSON
+// earlier versions of mark wrote. Fields unknown to this version are
+// ignored, so comments written by later versions parse as well. The returned
+// bool is false for comments holding no metadata, like ones written by
+// humans.
+func ParseAttachmentComment(comment string) (AttachmentMeta, bool) {
+	if checksum, ok := strings.CutPrefix(comment, attachmentChecksumPrefix); ok {
+		return AttachmentMeta{Checksum: checksum}, true
+	}
+
+	data := strings.TrimPrefix(comment, AttachmentMetaPrefix)
+
+	var meta AttachmentMeta
+
+	err := json.Unmarshal([]byte(data), &meta)
+	if err != nil || meta.Version < 1 {
+		return AttachmentMeta{}, false
+	}
+
+	return meta, true
+}
+
+// CreateAttachmentFromDataURI decodes an inline data URI such as
+// "data:image/png;base64,..." and uploads its content as a new attachment.
+// If name has no extension, one matching the media type of the data URI is
+// appended. The attachment is uploaded with the media type of the data URI.
+func (api *API) CreateAttachmentFromDataURI(
+	pageID string,
+	name string,
+	dataURI string,
+) (AttachmentInfo, error) {
+	mediaType, data, err := decodeDataURI(dataURI)
+	if err != nil {
+		return AttachmentInfo{}, karma.Format(
+			err,
+			"unable to decode data URI for attachment %q",
+			name,
+		)
+	}
+
+	if path.Ext(name) == "" {
+		extensions, _ := mime.ExtensionsByType(mediaType)
+		if len(extensions) > 0 {
+			name += extensions[0]
+		}
+	}
+
+	reader := bytes.NewReader(data)
+
+	err = api.CheckAttachmentSize(reader)
+	if err != nil {
+		return AttachmentInfo{}, err
+	}
+
+	ctx := context.Background()
+
+	form, err := getAttachmentPayload(ctx, name, "", reader)
+	if err != nil {
+		return AttachmentInfo{}, err
+	}
+
+	form.contentType = mediaType
+
+	return api.createAttachment(ctx, pageID, form)
+}
+
+// decodeDataURI returns the media type and the decoded data of the given
+// RFC 2397 data URI.
+func decodeDataURI(uri string) (string, []byte, error) {
+	rest, ok := strings.CutPrefix(uri, "data:")
+	if !ok {
+		return "", nil, errors.New("not a data URI: missing 'data:' prefix")
+	}
+
+	params, encoded, ok := strings.Cut(rest, ",")
+	if !ok {
+		return "", nil, errors.New("malformed data URI: missing ',' separator")
+	}
+
+	params, isBase64 := strings.CutSuffix(params, ";base64")
+
+	mediaType := "text/plain"
+	if params != "" && !strings.HasPrefix(params, ";") {
+		var err error
+
+		mediaType, _, err = mime.ParseMediaType(params)
+		if err != nil {
+			return "", nil, karma.Format(err, "invalid media type: %q", params)
+		}
+	}
+
+	if !isBase64 {
+		data, err := url.PathUnescape(encoded)
+		if err != nil {
+			return "", nil, karma.Format(err, "unable to unescape data")
+		}
+
+		return mediaType, []byte(data), nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(
+		strings.Join(strings.Fields(encoded), ""),
+	)
+	if err != nil {
+		return "", nil, karma.Format(err, "unable to decode base64 data")
+	}
+
+	return mediaType, data, nil
+}
+
+// GetAttachmentsIncludingAncestors returns the attachments of the given page
+// together with the attachments of all its ancestors. Attachments are
+// deduplicated by filename: the page's own attachments take precedence over
+// those of its ancestors and nearer ancestors over farther ones.
+func (api *API) GetAttachmentsIncludingAncestors(
+	pageID string,
+) ([]AttachmentInfo, error) {
+	page, err := api.GetPageByID(pageID)
+	if err != nil {
+		return nil, karma.Format(err, "unable to retrieve page %q", pageID)
+	}
+
+	ids := []string{page.ID}
+	for i := len(page.Ancestors) - 1; i >= 0; i-- {
+		ids = append(ids, page.Ancestors[i].ID)
+	}
+
+	seen := map[string]bool{}
+	attachments := []AttachmentInfo{}
+	for _, id := range ids {
+		infos, err := api.GetAttachments(id)
+		if err != nil {
+			return nil, karma.Format(
+				err,
+				"unable to retrieve attachments of page %q",
+				id,
+			)
+		}
+
+		for _, info := range infos {
+			if seen[info.Filename] {
+				continue
+			}
+
+			seen[info.Filename] = true
+			attachments = append(attachments, info)
+		}
+	}
+
+	return attachments, nil
+}
+
+// DownloadAttachment writes the content of the given attachment to writer.
+func (api *API) DownloadAttachment(info AttachmentInfo, writer io.Writer) error {
+	link := *api.rest.Api.BaseUrl
+	link.Path = ""
+	link.RawQuery = ""
+
+	download, err := url.Parse(path.Join(info.Links.Context, info.Links.Download))
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to parse download link of attachment %q",
+			info.Filename,
+		)
+	}
+
+	target := link.ResolveReference(download)
+
+	reqFn := func() (*http.Response, error) {
+		request, err := http.NewRequest(http.MethodGet, target.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		api.authorize(request)
+
+		return api.rest.Api.Client.Do(request)
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		time.Sleep(1 * time.Second)
+		return api.DownloadAttachment(info, writer)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return newErrorStatus(resp)
+	}
+
+	defer resp.Body.Close()
+
+	_, err = io.Copy(writer, resp.Body)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to download attachment %q",
+			info.Filename,
+		)
+	}
+
+	return nil
+}
+
+// authorize sets the credentials of the REST resource on a request that is
+// sent outside of the REST API.
+func (api *API) authorize(request *http.Request) {
+	if auth := api.rest.Api.BasicAuth; auth != nil {
+		request.SetBasicAuth(auth.Username, auth.Password)
+	} else if token := api.rest.Headers.Get("Authorization"); token != "" {
+		request.Header.Set("Authorization", token)
+	}
+}
+
+// DeleteAttachment deletes the given attachment. An attachment that doesn't
+// exist anymore is considered deleted.
+func (api *API) DeleteAttachment(attachID string) error {
+	var result interface{}
+
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resource("content/"+attachID, &result).Delete()
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		time.Sleep(1 * time.Second)
+		return api.DeleteAttachment(attachID)
+
+	case http.StatusOK, http.StatusNoContent:
+		return nil
+
+	case http.StatusNotFound:
+		// Confluence also responds with 404 if the user may view the
+		// attachment but not delete it, so make sure it's really gone.
+		exists, err := api.attachmentExists(attachID)
+		if err != nil {
+			return karma.Format(err, "unable to check whether attachment exists")
+		}
+
+		if exists {
+			return newErrorStatus(resp)
+		}
+
+		_ = resp.Body.Close()
+
+		return nil
+
+	default:
+		return newErrorStatus(resp)
+	}
+}
+
+// attachmentExists reports whether the given attachment can be retrieved.
+func (api *API) attachmentExists(attachID string) (bool, error) {
+	var result interface{}
+
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resource("content/"+attachID, &result).Get()
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return false, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		time.Sleep(1 * time.Second)
+		return api.attachmentExists(attachID)
+
+	case http.StatusOK:
+		return true, nil
+
+	case http.StatusNotFound:
+		_ = resp.Body.Close()
+		return false, nil
+
+	default:
+		return false, newErrorStatus(resp)
+	}
+}
+
+// OrphanedAttachments returns the attachments of the given page whose
+// filename is not among the given filenames, i.e. those that no longer have
+// a source file.
+func (api *API) OrphanedAttachments(
+	pageID string,
+	filenames []string,
+) ([]AttachmentInfo, error) {
+	remotes, err := api.GetAttachments(pageID)
+	if err != nil {
+		return nil, karma.Format(err, "unable to retrieve attachments")
+	}
+
+	keep := map[string]bool{}
+	for _, filename := range filenames {
+		keep[filename] = true
+	}
+
+	orphans := []AttachmentInfo{}
+	for _, remote := range remotes {
+		if !keep[remote.Filename] {
+			orphans = append(orphans, remote)
+		}
+	}
+
+	return orphans, nil
+}
+
+// PruneAttachments deletes the attachments of the given page that are not
+// among the given filenames. It returns the number of deleted attachments.
+func (api *API) PruneAttachments(pageID string, filenames []string) (int, error) {
+	orphans, err := api.OrphanedAttachments(pageID, filenames)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, orphan := range orphans {
+		log.Infof(nil, "deleting orphaned attachment: %q", orphan.Filename)
+
+		err := api.DeleteAttachment(orphan.ID)
+		if err != nil {
+			return removed, karma.Format(
+				err,
+				"unable to delete attachment %q",
+				orphan.Filename,
+			)
+		}
+
+		removed++
+	}
+
+	return removed, nil
+}
+
+// DeduplicateAttachments deletes all but the newest attachment of every
+// filename that is attached to the given page more than once. The page body
+// is left untouched: storage format references attachments by filename
+// (ri:attachment ri:filename), so its links resolve to the kept attachment.
+// It returns the number of deleted attachments.
+func (api *API) DeduplicateAttachments(pageID string) (int, error) {
+	attachments, err := api.GetAttachments(pageID)
+	if err != nil {
+		return 0, karma.Format(err, "unable to retrieve attachments")
+	}
+
+	newest := map[string]AttachmentInfo{}
+	for _, attachment := range attachments {
+		kept, ok := newest[attachment.Filename]
+		if !ok || isNewerAttachment(attachment, kept) {
+			newest[attachment.Filename] = attachment
+		}
+	}
+
+	removed := 0
+	for _, attachment := range attachments {
+		if attachment.ID == newest[attachment.Filename].ID {
+			continue
+		}
+
+		err := api.DeleteAttachment(attachment.ID)
+		if err != nil {
+			return removed, karma.Format(
+				err,
+				"unable to delete duplicate attachment %q",
+				attachment.ID,
+			)
+		}
+
+		removed++
+	}
+
+	return removed, nil
+}
+
+// isNewerAttachment reports whether a was uploaded after b. Attachments
+// without a parseable upload date are compared by ID, which grows over time.
+func isNewerAttachment(a, b AttachmentInfo) bool {
+	whenA, errA := time.Parse(time.RFC3339, a.Version.When)
+	whenB, errB := time.Parse(time.RFC3339, b.Version.When)
+	if errA == nil && errB == nil && !whenA.Equal(whenB) {
+		return whenA.After(whenB)
+	}
+
+	idA := strings.TrimPrefix(a.ID, "att")
+	idB := strings.TrimPrefix(b.ID, "att")
+	if len(idA) != len(idB) {
+		return len(idA) > len(idB)
+	}
+
+	return idA > idB
+}
+
+// AttachmentUpload is a single attachment uploaded by UploadAttachments.
+type AttachmentUpload struct {
+	// ID is the ID of the attachment a new version is uploaded for. A new
+	// attachment is created if it's empty.
+	ID      string
+	Name    string
+	Comment string
+	Reader  io.Reader
+}
+
+// AttachmentUploadResult is the outcome of a single AttachmentUpload. Err is
+// ctx.Err() for uploads that were cancelled.
+type AttachmentUploadResult struct {
+	Upload AttachmentUpload
+	Info   AttachmentInfo
+	Err    error
+}
+
+// UploadAttachments uploads the given attachments to the given page,
+// concurrency at a time, and returns the uploaded attachments in the order of
+// uploads. The first failed upload aborts the uploads in flight and skips the
+// pending ones; its error is returned.
+func (api *API) UploadAttachments(
+	pageID string,
+	uploads []AttachmentUpload,
+	concurrency int,
+) ([]AttachmentInfo, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results := api.uploadAttachments(ctx, pageID, uploads, concurrency, cancel)
+
+	// uploads aborted by the failure report context.Canceled, so the
+	// failure itself is the only other error
+	for _, result := range results {
+		if result.Err != nil && !errors.Is(result.Err, context.Canceled) {
+			return nil, karma.Format(
+				result.Err,
+				"unable to upload attachment %q",
+				result.Upload.Name,
+			)
+		}
+	}
+
+	infos := make([]AttachmentInfo, len(results))
+	for i, result := range results {
+		infos[i] = result.Info
+	}
+
+	return infos, nil
+}
+
+// UploadEachAttachment uploads the given attachments to the given page,
+// UploadConcurrency at a time. Unlike UploadAttachments a failed upload
+// doesn't affect the others. Once ctx is cancelled, uploads in flight are
+// aborted and pending ones are not started, while attachments that have
+// already been uploaded are kept. The results are in the order of uploads.
+func (api *API) UploadEachAttachment(
+	ctx context.Context,
+	pageID string,
+	uploads []AttachmentUpload,
+) []AttachmentUploadResult {
+	return api.uploadAttachments(ctx, pageID, uploads, api.UploadConcurrency, nil)
+}
+
+// uploadAttachments uploads attachments concurrency at a time and calls
+// cancel, if it's set, once an upload fails.
+func (api *API) uploadAttachments(
+	ctx context.Context,
+	pageID string,
+	uploads []AttachmentUpload,
+	concurrency int,
+	cancel context.CancelFunc,
+) []AttachmentUploadResult {
+	results := make([]AttachmentUploadResult, len(uploads))
+
+	parallel(len(uploads), max(concurrency, 1), func(i int) {
+		upload := uploads[i]
+		results[i].Upload = upload
+
+		err := ctx.Err()
+		if err != nil {
+			results[i].Err = err
+			return
+		}
+
+		if upload.ID == "" {
+			results[i].Info, err = api.CreateAttachmentContext(
+				ctx,
+				pageID,
+				upload.Name,
+				upload.Comment,
+				upload.Reader,
+			)
+		} else {
+			results[i].Info, err = api.UpdateAttachmentContext(
+				ctx,
+				pageID,
+				upload.ID,
+				upload.Name,
+				upload.Comment,
+				upload.Reader,
+			)
+		}
+
+		// report cancellation rather than the transport error it caused
+		if err != nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
+
+		if err != nil && cancel != nil {
+			cancel()
+		}
+
+		results[i].Err = err
+	})
+
+	return results
+}
+
+// UpdateAttachmentWithMessage is like UpdateAttachment but also records
+// message in the version history of the attachment, like the version message
+// of a page. The REST API takes no version message for uploaded data, so the
+// message is written to a version following the upload that keeps the data
+// and the stored comment.
+func (api *API) UpdateAttachmentWithMessage(
+	pageID string,
+	attachID string,
+	name string,
+	comment string,
+	message string,
+	reader io.Reader,
+) (AttachmentInfo, error) {
+	info, err := api.UpdateAttachment(pageID, attachID, name, comment, reader)
+	if err != nil {
+		return info, err
+	}
+
+	err = api.setAttachmentVersionMessage(pageID, attachID, message)
+	if err != nil {
+		return info, karma.Format(
+			err,
+			"unable to set version message of attachment %q",
+			name,
+		)
+	}
+
+	return info, nil
+}
+
+func (api *API) setAttachmentVersionMessage(
+	pageID string,
+	attachmentID string,
+	message string,
+) error {
+	attachment, err := api.getAttachment(attachmentID)
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]interface{}{
+		"id":    attachment.ID,
+		"type":  attachment.Type,
+		"title": attachment.Title,
+		"version": map[string]interface{}{
+			"number":  attachment.Version.Number + 1,
+			"message": message,
+		},
+		"metadata": map[string]interface{}{
+			"comment": attachment.Metadata.Comment,
+		},
+	}
+
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resource(
+			"content/"+pageID+"/child/attachment/"+attachmentID,
+			&map[string]interface{}{},
+		).Put(payload)
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		time.Sleep(1 * time.Second)
+		return api.setAttachmentVersionMessage(pageID, attachmentID, message)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return newErrorStatus(resp)
+	}
+
+	return nil
+}
+
+// EnsureAttachment makes sure the given page has an attachment with the given
+// name and the data of reader. The data is only uploaded if the page has no
+// such attachment yet or the checksum stored with the attachment differs;
+// the returned bool reports whether it was uploaded. The data is read from
+// the current position of reader, which is restored after hashing.
+func (api *API) EnsureAttachment(
+	pageID string,
+	name string,
+	reader io.ReadSeeker,
+) (AttachmentInfo, bool, error) {
+	offset, err := reader.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return AttachmentInfo{}, false, karma.Format(
+			err,
+			"unable to determine position in attachment %q",
+			name,
+		)
+	}
+
+	hash := sha256.New()
+
+	_, err = io.Copy(hash, reader)
+	if err != nil {
+		return AttachmentInfo{}, false, karma.Format(
+			err,
+			"unable to read attachment %q",
+			name,
+		)
+	}
+
+	_, err = reader.Seek(offset, io.SeekStart)
+	if err != nil {
+		return AttachmentInfo{}, false, karma.Format(
+			err,
+			"unable to rewind attachment %q",
+			name,
+		)
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	comment := FormatAttachmentComment(AttachmentMeta{Checksum: checksum})
+
+	attachments, err := api.GetAttachments(pageID)
+	if err != nil {
+		return AttachmentInfo{}, false, karma.Format(
+			err,
+			"unable to retrieve attachments of page %q",
+			pageID,
+		)
+	}
+
+	for _, attachment := range attachments {
+		if attachment.Filename != name {
+			continue
+		}
+
+		if attachmentChecksum(attachment.Metadata.Comment) == checksum {
+			return attachment, false, nil
+		}
+
+		info, err := api.UpdateAttachment(
+			pageID,
+			attachment.ID,
+			name,
+			comment,
+			reader,
+		)
+		if err != nil {
+			return AttachmentInfo{}, false, karma.Format(
+				err,
+				"unable to update attachment %q",
+				name,
+			)
+		}
+
+		return info, true, nil
+	}
+
+	info, err := api.CreateAttachment(pageID, name, comment, reader)
+	if err != nil {
+		return AttachmentInfo{}, false, karma.Format(
+			err,
+			"unable to create attachment %q",
+			name,
+		)
+	}
+
+	return info, true, nil
+}
+
+// attachmentChecksum returns the checksum stored in the given attachment
+// comment or an empty string if there is none.
+func attachmentChecksum(comment string) string {
+	meta, _ := ParseAttachmentComment(comment)
+
+	return meta.Checksum
+}
+
+// reAttachmentRef matches references to attachments in storage format. The
+// first group holds the attributes of the element, the third one is set if
+// the attachment belongs to another page, which is given by a child element.
+var reAttachmentRef = regexp.MustCompile(
+	`<ri:attachment\s([^>]*?)(/?)>` +
+		`(\s*<ri:(?:page|blog-post|content-entity)\b)?`,
+)
+
+// reAttachmentFilename matches the filename attribute of an ri:attachment
+// element.
+var reAttachmentFilename = regexp.MustCompile(`(?:^|\s)ri:filename="([^"]*)"`)
+
+// FindBrokenAttachmentRefs returns the filenames of the attachments the body
+// of the given page refers to which aren't attached to the page, e.g.
+// because their upload failed. References to attachments of other pages are
+// not checked.
+func (api *API) FindBrokenAttachmentRefs(pageID string) ([]string, error) {
+	storage, err := api.getPageStorage(pageID)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to retrieve body of page %q",
+			pageID,
+		)
+	}
+
+	attachments, err := api.GetAttachments(pageID)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to retrieve attachments of page %q",
+			pageID,
+		)
+	}
+
+	attached := map[string]bool{}
+	for _, attachment := range attachments {
+		attached[attachment.Filename] = true
+	}
+
+	broken := []string{}
+	for _, match := range reAttachmentRef.FindAllStringSubmatch(storage, -1) {
+		if match[2] == "" && match[3] != "" {
+			continue
+		}
+
+		attribute := reAttachmentFilename.FindStringSubmatch(match[1])
+		if attribute == nil {
+			continue
+		}
+
+		filename := html.UnescapeString(attribute[1])
+		if !attached[filename] && !slices.Contains(broken, filename) {
+			broken = append(broken, filename)
+		}
+	}
+
+	return broken, nil
+}
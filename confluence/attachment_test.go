@@ -0,0 +1,768 @@
+package confluence
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttachmentsConcurrently(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+			if r.Method == http.MethodGet {
+				fmt.Fprint(w, `{"results": []}`)
+				return
+			}
+
+			assert.Equal(t, "no-check", r.Header.Get("X-Atlassian-Token"))
+
+			file, header, err := r.FormFile("file")
+			if !assert.NoError(t, err) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			defer file.Close()
+
+			w.Header().Set("X-Request-Id", header.Filename)
+			fmt.Fprintf(
+				w,
+				`{"results": [{"id": "att-%s", "title": %q}]}`,
+				header.Filename,
+				header.Filename,
+			)
+		},
+	))
+	defer server.Close()
+
+	api := NewAPIWithToken(server.URL, "token")
+
+	names := []string{"a.png", "b.png", "c.png", "d.png"}
+	infos := make([]AttachmentInfo, len(names))
+	errs := make([]error, len(names))
+
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, errs[i] = api.GetAttachments("1")
+			if errs[i] != nil {
+				return
+			}
+
+			infos[i], errs[i] = api.CreateAttachment(
+				"1",
+				name,
+				"comment",
+				strings.NewReader("content of "+name),
+			)
+		}()
+	}
+	wg.Wait()
+
+	for i, name := range names {
+		assert.NoError(t, errs[i])
+		assert.Equal(t, "att-"+name, infos[i].ID)
+		assert.Equal(t, name, infos[i].Filename)
+	}
+}
+
+func TestAttachmentMetaRoundTrip(t *testing.T) {
+	attachment := attachmentContent{ID: "att1", Type: "attachment", Title: "a.png"}
+	attachment.Version.Number = 1
+	attachment.Metadata.Comment = "free text"
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			assert.Equal(t, "/rest/api/content/att1", r.URL.Path)
+			_ = json.NewEncoder(w).Encode(attachment)
+
+		case http.MethodPut:
+			assert.Equal(t, "/rest/api/content/1/child/attachment/att1", r.URL.Path)
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&attachment))
+			_ = json.NewEncoder(w).Encode(attachment)
+		}
+	})
+
+	_, err := api.GetAttachmentMeta("1", "att1")
+	assert.Error(t, err)
+
+	expected := AttachmentMeta{Source: "images/a.png", Checksum: "abc"}
+	assert.NoError(t, api.SetAttachmentMeta("1", "att1", expected))
+	assert.EqualValues(t, 2, attachment.Version.Number)
+
+	meta, err := api.GetAttachmentMeta("1", "att1")
+	assert.NoError(t, err)
+
+	expected.Version = attachmentMetaVersion
+	assert.Equal(t, expected, meta)
+}
+
+func TestParseAttachmentComment(t *testing.T) {
+	meta := AttachmentMeta{Source: "images/a.png", Checksum: "abc"}
+
+	comment := FormatAttachmentComment(meta)
+	assert.Equal(
+		t,
+		`mark:meta: {"version":1,"source":"images/a.png","checksum":"abc"}`,
+		comment,
+	)
+
+	meta.Version = attachmentMetaVersion
+
+	for _, test := range []struct {
+		comment string
+		meta    AttachmentMeta
+		ok      bool
+	}{
+		{comment, meta, true},
+		{`{"version":1,"source":"images/a.png","checksum":"abc"}`, meta, true},
+		{
+			`mark:meta: {"version":2,"checksum":"abc","origin":"ci"}`,
+			AttachmentMeta{Version: 2, Checksum: "abc"},
+			true,
+		},
+		{"mark:checksum: abc", AttachmentMeta{Checksum: "abc"}, true},
+		{"screenshot of the login page", AttachmentMeta{}, false},
+		{"", AttachmentMeta{}, false},
+	} {
+		meta, ok := ParseAttachmentComment(test.comment)
+		assert.Equal(t, test.ok, ok, test.comment)
+		assert.Equal(t, test.meta, meta, test.comment)
+	}
+}
+
+func TestGetAttachmentsExpand(t *testing.T) {
+	var expand []string
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/content/1/child/attachment", r.URL.Path)
+
+		expand = r.URL.Query()["expand"]
+		fmt.Fprint(w, `{"results": [{"id": "att1", "title": "a.png", "extensions": {"fileSize": 42}}]}`)
+	})
+
+	attachments, err := api.GetAttachments("1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"version"}, expand)
+	assert.EqualValues(t, 42, attachments[0].Extensions.FileSize)
+
+	api.AttachmentExpand = []string{"version", "container"}
+	_, err = api.GetAttachments("1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"version,container"}, expand)
+
+	api.AttachmentExpand = nil
+	_, err = api.GetAttachments("1")
+	assert.NoError(t, err)
+	assert.Empty(t, expand)
+}
+
+func TestCreateAttachmentFromDataURI(t *testing.T) {
+	// 1x1 transparent PNG
+	const pixel = "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNkYPhfDwAChwGA60e6kgAAAABJRU5ErkJggg=="
+
+	expected, err := base64.StdEncoding.DecodeString(pixel)
+	assert.NoError(t, err)
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/content/1/child/attachment", r.URL.Path)
+
+		file, header, err := r.FormFile("file")
+		if !assert.NoError(t, err) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		data, _ := io.ReadAll(file)
+		assert.Equal(t, expected, data)
+		assert.Equal(t, "image/png", header.Header.Get("Content-Type"))
+
+		fmt.Fprintf(w, `{"results": [{"id": "att1", "title": %q}]}`, header.Filename)
+	})
+
+	info, err := api.CreateAttachmentFromDataURI("1", "screenshot", "data:image/png;base64,"+pixel)
+	assert.NoError(t, err)
+	assert.Equal(t, "screenshot.png", info.Filename)
+
+	_, err = api.CreateAttachmentFromDataURI("1", "broken", "image/png;base64,"+pixel)
+	assert.Error(t, err)
+}
+
+func TestCreateAttachmentFromDataURIMediaType(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		_, header, err := r.FormFile("file")
+		if !assert.NoError(t, err) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		assert.Equal(t, "image/svg+xml", header.Header.Get("Content-Type"))
+
+		fmt.Fprintf(w, `{"results": [{"id": "att1", "title": %q}]}`, header.Filename)
+	})
+
+	info, err := api.CreateAttachmentFromDataURI(
+		"1",
+		"diagram.drawio",
+		"data:image/svg+xml,%3Csvg%2F%3E",
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, "diagram.drawio", info.Filename)
+}
+
+func TestDecodeDataURI(t *testing.T) {
+	mediaType, data, err := decodeDataURI("data:,hello%20world")
+	assert.NoError(t, err)
+	assert.Equal(t, "text/plain", mediaType)
+	assert.Equal(t, "hello world", string(data))
+
+	mediaType, data, err = decodeDataURI("data:text/csv;charset=utf-8;base64,YSxi")
+	assert.NoError(t, err)
+	assert.Equal(t, "text/csv", mediaType)
+	assert.Equal(t, "a,b", string(data))
+}
+
+func TestGetAttachmentsIncludingAncestors(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/api/content/3":
+			fmt.Fprint(w, `{"id": "3", "ancestors": [{"id": "1"}, {"id": "2"}]}`)
+		case "/rest/api/content/3/child/attachment":
+			fmt.Fprint(w, `{"results": [{"id": "own", "title": "logo.png"}]}`)
+		case "/rest/api/content/2/child/attachment":
+			fmt.Fprint(w, `{"results": [
+				{"id": "parent-logo", "title": "logo.png"},
+				{"id": "parent-diagram", "title": "diagram.png"}
+			]}`)
+		case "/rest/api/content/1/child/attachment":
+			fmt.Fprint(w, `{"results": [
+				{"id": "root-diagram", "title": "diagram.png"},
+				{"id": "root-banner", "title": "banner.png"}
+			]}`)
+		default:
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+	})
+
+	attachments, err := api.GetAttachmentsIncludingAncestors("3")
+	assert.NoError(t, err)
+
+	ids := []string{}
+	for _, attachment := range attachments {
+		ids = append(ids, attachment.ID)
+	}
+
+	assert.Equal(t, []string{"own", "parent-diagram", "root-banner"}, ids)
+}
+
+func TestDeduplicateAttachments(t *testing.T) {
+	var (
+		mutex   sync.Mutex
+		deleted []string
+	)
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		switch {
+		case r.URL.Path == "/rest/api/content/42/child/attachment":
+			_, _ = io.WriteString(w, `{"results": [
+				{"id": "att10", "title": "a.png", "version": {"when": "2024-01-01T10:00:00.000Z"}},
+				{"id": "att11", "title": "b.png", "version": {"when": "2024-01-01T10:00:00.000Z"}},
+				{"id": "att12", "title": "a.png", "version": {"when": "2024-02-01T10:00:00.000Z"}}
+			]}`)
+
+		case r.Method == http.MethodDelete:
+			deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/rest/api/content/"))
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL)
+		}
+	})
+
+	removed, err := api.DeduplicateAttachments("42")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.Equal(t, []string{"att10"}, deleted)
+}
+
+func TestUploadEachAttachmentCancel(t *testing.T) {
+	var (
+		mutex    sync.Mutex
+		uploaded []string
+		blocked  = make(chan struct{})
+	)
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		_, header, err := r.FormFile("file")
+		assert.NoError(t, err)
+
+		mutex.Lock()
+		uploaded = append(uploaded, header.Filename)
+		mutex.Unlock()
+
+		if header.Filename != "done.txt" {
+			close(blocked)
+			<-r.Context().Done()
+			return
+		}
+
+		_, _ = fmt.Fprintf(w, `{"results": [{"id": "att1", "title": %q}]}`, header.Filename)
+	})
+
+	api.UploadConcurrency = 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-blocked
+		cancel()
+	}()
+
+	results := api.UploadEachAttachment(ctx, "42", []AttachmentUpload{
+		{Name: "done.txt", Reader: strings.NewReader("a")},
+		{Name: "aborted.txt", Reader: strings.NewReader("b")},
+		{Name: "pending.txt", Reader: strings.NewReader("c")},
+	})
+
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, "att1", results[0].Info.ID)
+	assert.ErrorIs(t, results[1].Err, context.Canceled)
+	assert.ErrorIs(t, results[2].Err, context.Canceled)
+
+	assert.Equal(t, []string{"done.txt", "aborted.txt"}, uploaded)
+}
+
+func TestCreateAttachmentRetrySendsFullBody(t *testing.T) {
+	var files []string
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		file, _, err := r.FormFile("file")
+		assert.NoError(t, err)
+
+		content, err := io.ReadAll(file)
+		assert.NoError(t, err)
+
+		files = append(files, string(content))
+
+		if len(files) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		_, _ = io.WriteString(w, `{"results": [{"id": "att1", "title": "a.txt"}]}`)
+	})
+
+	info, err := api.CreateAttachment("42", "a.txt", "", strings.NewReader("file content"))
+	assert.NoError(t, err)
+	assert.Equal(t, "att1", info.ID)
+	assert.Equal(t, []string{"file content", "file content"}, files)
+}
+
+// zeroReader produces size zero bytes and counts how many were read.
+type zeroReader struct {
+	size int64
+	read atomic.Int64
+}
+
+func (reader *zeroReader) Read(data []byte) (int, error) {
+	remaining := reader.size - reader.read.Load()
+	if remaining <= 0 {
+		return 0, io.EOF
+	}
+
+	n := min(int64(len(data)), remaining)
+	clear(data[:n])
+	reader.read.Add(n)
+
+	return int(n), nil
+}
+
+func TestCreateAttachmentStreamsFile(t *testing.T) {
+	const size = 64 << 20
+
+	file := &zeroReader{size: size}
+
+	var readAhead, received int64
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "no-check", r.Header.Get("X-Atlassian-Token"))
+
+		reader, err := r.MultipartReader()
+		assert.NoError(t, err)
+
+		part, err := reader.NextPart()
+		assert.NoError(t, err)
+		assert.Equal(t, "video.mp4", part.FileName())
+
+		head, err := io.CopyN(io.Discard, part, 1<<20)
+		assert.NoError(t, err)
+
+		readAhead = file.read.Load() - head
+
+		rest, err := io.Copy(io.Discard, part)
+		assert.NoError(t, err)
+
+		received = head + rest
+
+		part, err = reader.NextPart()
+		assert.NoError(t, err)
+		assert.Equal(t, "comment", part.FormName())
+
+		comment, _ := io.ReadAll(part)
+		assert.Equal(t, "recording", string(comment))
+
+		_, _ = io.WriteString(w, `{"results": [{"id": "att1", "title": "video.mp4"}]}`)
+	})
+
+	_, err := api.CreateAttachment("42", "video.mp4", "recording", file)
+	assert.NoError(t, err)
+	assert.EqualValues(t, size, received)
+	assert.Less(t, readAhead, int64(16<<20))
+}
+
+func TestCheckAttachmentSize(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request: %s", r.URL)
+	})
+
+	// the limit is configurable per instance, so there's none by default
+	assert.Zero(t, api.MaxAttachmentSize)
+
+	api.MaxAttachmentSize = 4
+
+	reader := strings.NewReader("12345")
+	_, _ = reader.Seek(1, io.SeekStart)
+
+	assert.NoError(t, api.CheckAttachmentSize(reader))
+
+	offset, _ := reader.Seek(0, io.SeekCurrent)
+	assert.EqualValues(t, 1, offset)
+
+	_, err := api.CreateAttachment("42", "big.bin", "", strings.NewReader("12345"))
+	assert.Equal(t, ErrAttachmentTooLarge{Size: 5, Limit: 4}, err)
+}
+
+func TestUpdateAttachmentWithMessage(t *testing.T) {
+	var payload map[string]interface{}
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rest/api/content/42/child/attachment/att1/data":
+			_, _ = io.WriteString(w, `{"id": "att1", "title": "a.png"}`)
+
+		case r.URL.Path == "/rest/api/content/att1":
+			_, _ = io.WriteString(w, `{
+				"id": "att1",
+				"type": "attachment",
+				"title": "a.png",
+				"version": {"number": 3},
+				"metadata": {"comment": "mark:checksum: abc"}
+			}`)
+
+		case r.URL.Path == "/rest/api/content/42/child/attachment/att1":
+			assert.Equal(t, http.MethodPut, r.Method)
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			_, _ = io.WriteString(w, `{}`)
+
+		default:
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+	})
+
+	info, err := api.UpdateAttachmentWithMessage(
+		"42",
+		"att1",
+		"a.png",
+		"mark:checksum: abc",
+		"Regenerated diagram",
+		strings.NewReader("png"),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, "att1", info.ID)
+
+	assert.Equal(t, map[string]interface{}{
+		"number":  4.0,
+		"message": "Regenerated diagram",
+	}, payload["version"])
+	assert.Equal(t, map[string]interface{}{
+		"comment": "mark:checksum: abc",
+	}, payload["metadata"])
+}
+
+func TestEnsureAttachment(t *testing.T) {
+	var (
+		comment string
+		uploads []string
+	)
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			if comment == "" {
+				_, _ = io.WriteString(w, `{"results": []}`)
+				return
+			}
+
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"results": []map[string]interface{}{{
+					"id":       "att1",
+					"title":    "a.txt",
+					"metadata": map[string]interface{}{"comment": comment},
+				}},
+			})
+			return
+		}
+
+		uploads = append(uploads, r.URL.Path)
+		comment = r.FormValue("comment")
+
+		_, _ = io.WriteString(w, `{"results": [{"id": "att1", "title": "a.txt"}]}`)
+	})
+
+	_, uploaded, err := api.EnsureAttachment("42", "a.txt", strings.NewReader("one"))
+	assert.NoError(t, err)
+	assert.True(t, uploaded)
+
+	_, uploaded, err = api.EnsureAttachment("42", "a.txt", strings.NewReader("one"))
+	assert.NoError(t, err)
+	assert.False(t, uploaded)
+
+	_, uploaded, err = api.EnsureAttachment("42", "a.txt", strings.NewReader("two"))
+	assert.NoError(t, err)
+	assert.True(t, uploaded)
+
+	assert.Equal(t, []string{
+		"/rest/api/content/42/child/attachment",
+		"/rest/api/content/42/child/attachment/att1/data",
+	}, uploads)
+}
+
+func TestGetAttachmentsPaginated(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/content/1/child/attachment", r.URL.Path)
+
+		if r.URL.Query().Get("start") == "" {
+			_, _ = io.WriteString(w, `{
+				"results": [{"id": "att1", "title": "a.png"}],
+				"_links": {
+					"context": "/wiki",
+					"next": "/rest/api/content/1/child/attachment?limit=1&start=1"
+				}
+			}`)
+			return
+		}
+
+		_, _ = io.WriteString(w, `{
+			"results": [{"id": "att2", "title": "b.png"}],
+			"_links": {"context": "/wiki"}
+		}`)
+	})
+
+	attachments, err := api.GetAttachments("1")
+	assert.NoError(t, err)
+	assert.Len(t, attachments, 2)
+	assert.Equal(t, "att1", attachments[0].ID)
+	assert.Equal(t, "att2", attachments[1].ID)
+	assert.Equal(t, "/wiki", attachments[1].Links.Context)
+}
+
+func TestPruneAttachments(t *testing.T) {
+	var deleted []string
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rest/api/content/42/child/attachment":
+			_, _ = io.WriteString(w, `{"results": [
+				{"id": "att1", "title": "kept.png"},
+				{"id": "att2", "title": "removed.png"},
+				{"id": "att3", "title": "gone.png"}
+			]}`)
+
+		case r.Method == http.MethodGet && r.URL.Path == "/rest/api/content/att3":
+			w.WriteHeader(http.StatusNotFound)
+
+		case r.Method == http.MethodDelete:
+			id := strings.TrimPrefix(r.URL.Path, "/rest/api/content/")
+			deleted = append(deleted, id)
+
+			if id == "att3" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL)
+		}
+	})
+
+	removed, err := api.PruneAttachments("42", []string{"kept.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	assert.Equal(t, []string{"att2", "att3"}, deleted)
+}
+
+func TestDeleteAttachmentNotFoundButExists(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/content/att1", r.URL.Path)
+
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = io.WriteString(w, `{"id": "att1", "type": "attachment"}`)
+	})
+
+	err := api.DeleteAttachment("att1")
+	assert.ErrorContains(t, err, "404")
+}
+
+func TestUploadAttachmentsFailure(t *testing.T) {
+	var (
+		mutex    sync.Mutex
+		uploaded []string
+	)
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		_, header, err := r.FormFile("file")
+		assert.NoError(t, err)
+
+		mutex.Lock()
+		uploaded = append(uploaded, header.Filename)
+		mutex.Unlock()
+
+		if header.Filename == "broken.txt" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		_, _ = fmt.Fprintf(w, `{"results": [{"id": "att1", "title": %q}]}`, header.Filename)
+	})
+
+	infos, err := api.UploadAttachments("42", []AttachmentUpload{
+		{Name: "done.txt", Reader: strings.NewReader("a")},
+		{Name: "broken.txt", Reader: strings.NewReader("b")},
+		{Name: "pending.txt", Reader: strings.NewReader("c")},
+	}, 1)
+
+	assert.Nil(t, infos)
+	assert.ErrorContains(t, err, `unable to upload attachment "broken.txt"`)
+	assert.Equal(t, []string{"done.txt", "broken.txt"}, uploaded)
+}
+
+func TestUploadAttachmentsOrder(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		_, header, err := r.FormFile("file")
+		assert.NoError(t, err)
+
+		_, _ = fmt.Fprintf(w, `{"results": [{"id": %q, "title": %q}]}`, "att-"+header.Filename, header.Filename)
+	})
+
+	uploads := []AttachmentUpload{}
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		uploads = append(uploads, AttachmentUpload{Name: name, Reader: strings.NewReader(name)})
+	}
+
+	infos, err := api.UploadAttachments("42", uploads, 3)
+	assert.NoError(t, err)
+	assert.Len(t, infos, 4)
+
+	for i, upload := range uploads {
+		assert.Equal(t, "att-"+upload.Name, infos[i].ID)
+	}
+}
+
+func TestEnsureAttachmentUnchangedReturnsExisting(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("unexpected upload: %s %s", r.Method, r.URL)
+			return
+		}
+
+		_, _ = io.WriteString(w, `{"results": [{
+			"id": "att7",
+			"title": "a.txt",
+			"metadata": {"comment": "mark:checksum: `+
+			`7692c3ad3540bb803c020b3aee66cd8887123234ea0c6e7143c0add73ff431ed"}
+		}]}`)
+	})
+
+	info, uploaded, err := api.EnsureAttachment("42", "a.txt", strings.NewReader("one"))
+	assert.NoError(t, err)
+	assert.False(t, uploaded)
+	assert.Equal(t, "att7", info.ID)
+}
+
+func TestEnsureAttachmentKeepsReaderOffset(t *testing.T) {
+	var uploaded string
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_, _ = io.WriteString(w, `{"results": []}`)
+			return
+		}
+
+		file, _, err := r.FormFile("file")
+		if assert.NoError(t, err) {
+			data, _ := io.ReadAll(file)
+			uploaded = string(data)
+		}
+
+		_, _ = io.WriteString(w, `{"results": [{"id": "att1", "title": "a.txt"}]}`)
+	})
+
+	reader := strings.NewReader("header:data")
+	_, err := reader.Seek(int64(len("header:")), io.SeekStart)
+	assert.NoError(t, err)
+
+	_, _, err = api.EnsureAttachment("42", "a.txt", reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "data", uploaded)
+}
+
+func TestFindBrokenAttachmentRefs(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/api/content/42":
+			_, _ = io.WriteString(w, `{"body": {"storage": {"value": `+
+				`"<ac:image><ri:attachment ri:filename=\"ok.png\" /></ac:image>`+
+				`<ac:image><ri:attachment ri:filename=\"a &amp; b.png\" /></ac:image>`+
+				`<ac:image><ri:attachment ri:filename=\"missing.png\"></ri:attachment></ac:image>`+
+				`<ac:image><ri:attachment ri:filename=\"other.png\">`+
+				`<ri:page ri:content-title=\"Other\" /></ri:attachment></ac:image>`+
+				`<ac:image><ri:attachment ri:filename=\"saved.png\" ri:version-at-save=\"1\">`+
+				`<ri:page ri:content-title=\"Other\" /></ri:attachment></ac:image>`+
+				`<ac:image><ri:attachment ri:filename=\"gone.png\" ri:version-at-save=\"2\" />`+
+				`</ac:image>"}}}`)
+
+		case "/rest/api/content/42/child/attachment":
+			_, _ = io.WriteString(w, `{"results": [{"id": "att1", "title": "ok.png"}]}`)
+
+		default:
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+	})
+
+	broken, err := api.FindBrokenAttachmentRefs("42")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a & b.png", "missing.png", "gone.png"}, broken)
+}
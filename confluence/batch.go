@@ -0,0 +1,282 @@
+package confluence
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/reconquest/karma-go"
+)
+
+// publishConcurrency is the number of pages PublishBatch publishes at the
+// same time.
+const publishConcurrency = 4
+
+// PublishItem is a single page published by PublishBatch.
+type PublishItem struct {
+	Space string
+	Title string
+
+	// Parent is the title of the parent page, which is either another item
+	// of the batch or a page that already exists in Space. The page is
+	// created at the top level of the space if Parent is empty.
+	Parent string
+
+	// Body is the page content in storage format. It may link to other
+	// items of the batch.
+	Body string
+}
+
+// Actions reported in PublishResult.
+const (
+	ActionCreated = "created"
+	ActionUpdated = "updated"
+)
+
+// PublishResult is the outcome of publishing a single PublishItem. It can be
+// marshaled to JSON, e.g. for a build summary.
+type PublishResult struct {
+	Item PublishItem `json:"-"`
+	Page *PageInfo   `json:"-"`
+
+	// Action is either ActionCreated or ActionUpdated. It's empty if the page
+	// couldn't be created.
+	Action string `json:"action,omitempty"`
+
+	PageID string `json:"pageId,omitempty"`
+	URL    string `json:"url,omitempty"`
+
+	// VersionBefore is zero for created pages.
+	VersionBefore int64 `json:"versionBefore"`
+	VersionAfter  int64 `json:"versionAfter,omitempty"`
+
+	Err error `json:"-"`
+}
+
+// MarshalJSON marshals the result with Err as an "error" string.
+func (result PublishResult) MarshalJSON() ([]byte, error) {
+	type plain PublishResult
+
+	value := struct {
+		plain
+		Error string `json:"error,omitempty"`
+	}{plain: plain(result)}
+
+	if result.Err != nil {
+		value.Error = result.Err.Error()
+	}
+
+	return json.Marshal(value)
+}
+
+// PublishBatch publishes the given pages. Parents are published before their
+// children and the pages of one hierarchy level are published concurrently.
+// Bodies are only written once every page of the batch exists, so links
+// between the pages resolve.
+//
+// The results are in the order of items. An error is returned only if the
+// batch can't be ordered at all; failures of single pages, which also fail
+// their descendants, are reported in the results.
+func (api *API) PublishBatch(items []PublishItem) ([]PublishResult, error) {
+	levels, err := publishLevels(items)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]PublishResult, len(items))
+	for i, item := range items {
+		results[i].Item = item
+	}
+
+	for _, level := range levels {
+		parallel(len(level), publishConcurrency, func(i int) {
+			index := level[i]
+			page, action, err := api.ensureBatchPage(items, results, index)
+			if err != nil {
+				results[index].Err = err
+				return
+			}
+
+			results[index].Page = page
+			results[index].Action = action
+			results[index].PageID = page.ID
+
+			if action == ActionUpdated {
+				results[index].VersionBefore = page.Version.Number
+			}
+		})
+	}
+
+	parallel(len(items), publishConcurrency, func(index int) {
+		if results[index].Err != nil {
+			return
+		}
+
+		page, err := api.writeBatchPage(results[index].Page.ID, items[index].Body)
+		if err != nil {
+			results[index].Err = err
+			return
+		}
+
+		results[index].Page = page
+		results[index].VersionAfter = page.Version.Number
+
+		if page.Links.Full != "" {
+			results[index].URL = api.BaseURL + page.Links.Full
+		}
+	})
+
+	return results, nil
+}
+
+// publishLevels groups the indexes of items by their depth in the batch
+// hierarchy. Items whose parent is not part of the batch are at depth zero.
+func publishLevels(items []PublishItem) ([][]int, error) {
+	byTitle := map[string]int{}
+	for i, item := range items {
+		key := item.Space + "/" + item.Title
+		if _, ok := byTitle[key]; ok {
+			return nil, karma.Describe("title", item.Title).Reason(
+				"page is listed more than once in the batch",
+			)
+		}
+
+		byTitle[key] = i
+	}
+
+	depths := make([]int, len(items))
+	for i := range items {
+		depth := 0
+		for current := i; items[current].Parent != ""; depth++ {
+			parent, ok := byTitle[items[current].Space+"/"+items[current].Parent]
+			if !ok {
+				break
+			}
+
+			if depth >= len(items) {
+				return nil, karma.Describe("title", items[i].Title).Reason(
+					"page is its own ancestor",
+				)
+			}
+
+			current = parent
+		}
+
+		depths[i] = depth
+	}
+
+	levels := [][]int{}
+	for i, depth := range depths {
+		for len(levels) <= depth {
+			levels = append(levels, []int{})
+		}
+
+		levels[depth] = append(levels[depth], i)
+	}
+
+	return levels, nil
+}
+
+// ensureBatchPage returns the page of the item with the given index, creating
+// it without content if it doesn't exist yet, along with the action taken.
+func (api *API) ensureBatchPage(
+	items []PublishItem,
+	results []PublishResult,
+	index int,
+) (*PageInfo, string, error) {
+	item := items[index]
+
+	var parent *PageInfo
+	if item.Parent != "" {
+		for i, candidate := range items {
+			if candidate.Space != item.Space || candidate.Title != item.Parent {
+				continue
+			}
+
+			if results[i].Err != nil {
+				return nil, "", karma.Format(
+					results[i].Err,
+					"unable to publish parent page %q",
+					item.Parent,
+				)
+			}
+
+			parent = results[i].Page
+		}
+
+		if parent == nil {
+			var err error
+
+			parent, err = api.FindPage(item.Space, item.Parent, "page")
+			if err != nil {
+				return nil, "", karma.Format(
+					err,
+					"unable to find parent page %q",
+					item.Parent,
+				)
+			}
+
+			if parent == nil {
+				return nil, "", fmt.Errorf("parent page %q not found", item.Parent)
+			}
+		}
+	}
+
+	page, err := api.FindPage(item.Space, item.Title, "page")
+	if err != nil {
+		return nil, "", karma.Format(err, "unable to find page %q", item.Title)
+	}
+
+	if page != nil {
+		return page, ActionUpdated, nil
+	}
+
+	page, err = api.CreatePage(item.Space, "page", parent, item.Title, "")
+	if err != nil {
+		return nil, "", karma.Format(err, "unable to create page %q", item.Title)
+	}
+
+	return page, ActionCreated, nil
+}
+
+// writeBatchPage replaces the body of the given page.
+func (api *API) writeBatchPage(pageID, body string) (*PageInfo, error) {
+	page, err := api.GetPageByID(pageID)
+	if err != nil {
+		return nil, karma.Format(err, "unable to retrieve page %q", pageID)
+	}
+
+	err = api.UpdatePage(page, body, false, "", nil, "full-width", "", false)
+	if err != nil {
+		return nil, karma.Format(err, "unable to update page %q", page.Title)
+	}
+
+	page.Version.Number++
+
+	return page, nil
+}
+
+// parallel calls fn for every index below count, running at most limit calls
+// at the same time, and waits for all of them to return.
+func parallel(count, limit int, fn func(index int)) {
+	var (
+		wg        sync.WaitGroup
+		semaphore = make(chan struct{}, limit)
+	)
+
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		semaphore <- struct{}{}
+
+		go func(i int) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+
+			fn(i)
+		}(i)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,163 @@
+package confluence
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// batchStore emulates the page endpoints used by PublishBatch.
+type batchStore struct {
+	mu      sync.Mutex
+	pages   map[string]*PageInfo
+	created []string
+	bodies  map[string]string
+}
+
+func (s *batchStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/rest/api/content"), "/")
+
+	switch {
+	case r.Method == http.MethodGet && id == "":
+		results := []*PageInfo{}
+		for _, page := range s.pages {
+			if page.Title == r.URL.Query().Get("title") {
+				results = append(results, page)
+			}
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+
+	case r.Method == http.MethodPost:
+		var payload struct {
+			Title     string `json:"title"`
+			Ancestors []struct {
+				ID string `json:"id"`
+			} `json:"ancestors"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+
+		page := &PageInfo{
+			ID:    strconv.Itoa(len(s.pages) + 1),
+			Title: payload.Title,
+			Type:  "page",
+		}
+		page.Version.Number = 1
+
+		for _, ancestor := range payload.Ancestors {
+			parent := s.pages[ancestor.ID]
+			page.Ancestors = append(page.Ancestors, parent.Ancestors...)
+			page.Ancestors = append(page.Ancestors, struct {
+				ID    string `json:"id"`
+				Title string `json:"title"`
+			}{parent.ID, parent.Title})
+		}
+
+		s.pages[page.ID] = page
+		s.created = append(s.created, page.Title)
+
+		_ = json.NewEncoder(w).Encode(page)
+
+	case r.Method == http.MethodGet:
+		_ = json.NewEncoder(w).Encode(s.pages[id])
+
+	case r.Method == http.MethodPut:
+		var payload struct {
+			Body struct {
+				Storage struct {
+					Value string `json:"value"`
+				} `json:"storage"`
+			} `json:"body"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+
+		s.pages[id].Version.Number++
+		s.bodies[s.pages[id].Title] = payload.Body.Storage.Value
+
+		_, _ = w.Write([]byte(`{}`))
+	}
+}
+
+func TestPublishBatch(t *testing.T) {
+	store := &batchStore{
+		pages:  map[string]*PageInfo{},
+		bodies: map[string]string{},
+	}
+	api := newTestAPI(t, store.ServeHTTP)
+
+	link := `<ac:link><ri:page ri:content-title="Parent" /></ac:link>`
+
+	results, err := api.PublishBatch([]PublishItem{
+		{Space: "DOC", Title: "Child A", Parent: "Parent", Body: link},
+		{Space: "DOC", Title: "Child B", Parent: "Parent", Body: "<p>b</p>"},
+		{Space: "DOC", Title: "Parent", Body: "<p>parent</p>"},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Parent", store.created[0])
+	assert.ElementsMatch(t, []string{"Child A", "Child B"}, store.created[1:])
+
+	for _, result := range results {
+		assert.NoError(t, result.Err)
+		assert.Equal(t, result.Item.Title, result.Page.Title)
+
+		if result.Item.Parent != "" {
+			assert.Equal(t, "Parent", result.Page.Ancestors[0].Title)
+		}
+	}
+
+	assert.Equal(t, map[string]string{
+		"Parent":  "<p>parent</p>",
+		"Child A": link,
+		"Child B": "<p>b</p>",
+	}, store.bodies)
+}
+
+func TestPublishBatchCycle(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request: %s", r.URL)
+	})
+
+	_, err := api.PublishBatch([]PublishItem{
+		{Space: "DOC", Title: "A", Parent: "B"},
+		{Space: "DOC", Title: "B", Parent: "A"},
+	})
+	assert.Error(t, err)
+}
+
+func TestPublishBatchResult(t *testing.T) {
+	store := &batchStore{
+		pages:  map[string]*PageInfo{},
+		bodies: map[string]string{},
+	}
+	api := newTestAPI(t, store.ServeHTTP)
+
+	results, err := api.PublishBatch([]PublishItem{
+		{Space: "DOC", Title: "New", Body: "<p>new</p>"},
+	})
+	assert.NoError(t, err)
+
+	result := results[0]
+	assert.NoError(t, result.Err)
+	assert.Equal(t, ActionCreated, result.Action)
+	assert.Equal(t, "1", result.PageID)
+	assert.Equal(t, int64(0), result.VersionBefore)
+	assert.Equal(t, int64(2), result.VersionAfter)
+
+	data, err := json.Marshal(result)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"action": "created",
+		"pageId": "1",
+		"versionBefore": 0,
+		"versionAfter": 2
+	}`, string(data))
+}
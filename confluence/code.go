@@ -0,0 +1,156 @@
+package confluence
+
+import (
+	"html"
+	"net/url"
+	"strings"
+)
+
+// codeLanguages lists the languages of the code macro, mapped from common
+// names in markdown code fences to the names Confluence expects.
+var codeLanguages = map[string]string{
+	"actionscript3": "actionscript3",
+	"applescript":   "applescript",
+	"bash":          "bash",
+	"sh":            "bash",
+	"shell":         "bash",
+	"c#":            "c#",
+	"csharp":        "c#",
+	"c":             "cpp",
+	"cpp":           "cpp",
+	"c++":           "cpp",
+	"coldfusion":    "coldfusion",
+	"css":           "css",
+	"delphi":        "delphi",
+	"diff":          "diff",
+	"erl":           "erl",
+	"erlang":        "erl",
+	"go":            "go",
+	"golang":        "go",
+	"groovy":        "groovy",
+	"html":          "html",
+	"java":          "java",
+	"javafx":        "jfx",
+	"jfx":           "jfx",
+	"javascript":    "js",
+	"js":            "js",
+	"json":          "json",
+	"kotlin":        "kotlin",
+	"perl":          "perl",
+	"php":           "php",
+	"powershell":    "powershell",
+	"py":            "py",
+	"python":        "py",
+	"ruby":          "ruby",
+	"rust":          "rust",
+	"sass":          "sass",
+	"scala":         "scala",
+	"sql":           "sql",
+	"swift":         "swift",
+	"text":          "text",
+	"typescript":    "typescript",
+	"vb":            "vb",
+	"xml":           "xml",
+	"yaml":          "yml",
+	"yml":           "yml",
+}
+
+// CodeBlockMarkup returns the code macro in storage format showing the given
+// code. Languages the macro doesn't support are highlighted as plain text.
+// The title is omitted if it's empty.
+func (api *API) CodeBlockMarkup(
+	language string,
+	title string,
+	code string,
+	lineNumbers bool,
+) string {
+	name, ok := codeLanguages[strings.ToLower(language)]
+	if !ok {
+		name = "text"
+	}
+
+	var markup strings.Builder
+
+	markup.WriteString(`<ac:structured-macro ac:name="code">`)
+	markup.WriteString(
+		`<ac:parameter ac:name="language">` + name + `</ac:parameter>`,
+	)
+
+	if title != "" {
+		markup.WriteString(
+			`<ac:parameter ac:name="title">` +
+				html.EscapeString(title) +
+				`</ac:parameter>`,
+		)
+	}
+
+	if lineNumbers {
+		markup.WriteString(`<ac:parameter ac:name="linenumbers">true</ac:parameter>`)
+	}
+
+	// The only way to escape the CDATA end marker is to split it into two
+	// CDATA sections.
+	markup.WriteString(
+		`<ac:plain-text-body><![CDATA[` +
+			strings.ReplaceAll(code, "]]>", "]]]]><![CDATA[>") +
+			`]]></ac:plain-text-body>`,
+	)
+	markup.WriteString(`</ac:structured-macro>`)
+
+	return markup.String()
+}
+
+// ExpandMacroMarkup returns the expand macro in storage format, which is what
+// markdown <details> sections map to. The body is storage format itself and
+// is embedded as rich text, so macros nested in it, including their CDATA
+// sections, are kept intact. The title is omitted if it's empty, in which
+// case Confluence shows its default "Click here to expand...".
+func (api *API) ExpandMacroMarkup(title string, body string) string {
+	var markup strings.Builder
+
+	markup.WriteString(`<ac:structured-macro ac:name="expand">`)
+
+	if title != "" {
+		markup.WriteString(
+			`<ac:parameter ac:name="title">` +
+				html.EscapeString(title) +
+				`</ac:parameter>`,
+		)
+	}
+
+	markup.WriteString(`<ac:rich-text-body>` + body + `</ac:rich-text-body>`)
+	markup.WriteString(`</ac:structured-macro>`)
+
+	return markup.String()
+}
+
+// smartLinkAppearances maps the smart link appearances to the values of the
+// data-card-appearance attribute Confluence stores them as.
+var smartLinkAppearances = map[string]string{
+	"inline": "inline",
+	"card":   "block",
+	"embed":  "embed",
+}
+
+// SmartLinkMarkup returns the given URL as smart link in storage format,
+// which Confluence renders with the given appearance: "inline", "card" or
+// "embed". URLs other than http and https ones and unknown appearances fall
+// back to a plain link.
+func (api *API) SmartLinkMarkup(link string, appearance string) string {
+	escaped := html.EscapeString(link)
+
+	value, ok := smartLinkAppearances[appearance]
+
+	target, err := url.Parse(link)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") ||
+		target.Host == "" {
+		ok = false
+	}
+
+	if !ok {
+		return `<a href="` + escaped + `">` + escaped + `</a>`
+	}
+
+	return `<a href="` + escaped + `" data-card-appearance="` + value + `">` +
+		escaped + `</a>`
+}
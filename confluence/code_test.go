@@ -0,0 +1,100 @@
+package confluence
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodeBlockMarkup(t *testing.T) {
+	api := NewAPI("http://confluence.example.com", "user", "password")
+
+	assert.Equal(
+		t,
+		`<ac:structured-macro ac:name="code">`+
+			`<ac:parameter ac:name="language">go</ac:parameter>`+
+			`<ac:parameter ac:name="title">main.go &amp; more</ac:parameter>`+
+			`<ac:parameter ac:name="linenumbers">true</ac:parameter>`+
+			`<ac:plain-text-body><![CDATA[func main() {`+"\n"+
+			`	_ = a[b[0]]]]]><![CDATA[>1`+"\n"+
+			`}]]></ac:plain-text-body>`+
+			`</ac:structured-macro>`,
+		api.CodeBlockMarkup(
+			"golang",
+			"main.go & more",
+			"func main() {\n\t_ = a[b[0]]]>1\n}",
+			true,
+		),
+	)
+
+	assert.Equal(
+		t,
+		`<ac:structured-macro ac:name="code">`+
+			`<ac:parameter ac:name="language">text</ac:parameter>`+
+			`<ac:plain-text-body><![CDATA[x]]></ac:plain-text-body>`+
+			`</ac:structured-macro>`,
+		api.CodeBlockMarkup("brainfuck", "", "x", false),
+	)
+}
+
+func TestExpandMacroMarkup(t *testing.T) {
+	api := NewAPI("http://confluence.example.com", "user", "password")
+
+	code := api.CodeBlockMarkup("go", "", "a[b[0]]>1", false)
+
+	assert.Equal(
+		t,
+		`<ac:structured-macro ac:name="expand">`+
+			`<ac:parameter ac:name="title">Details &lt;click&gt;</ac:parameter>`+
+			`<ac:rich-text-body><p>Example:</p>`+
+			`<ac:structured-macro ac:name="code">`+
+			`<ac:parameter ac:name="language">go</ac:parameter>`+
+			`<ac:plain-text-body><![CDATA[a[b[0]]]]><![CDATA[>1]]></ac:plain-text-body>`+
+			`</ac:structured-macro>`+
+			`</ac:rich-text-body>`+
+			`</ac:structured-macro>`,
+		api.ExpandMacroMarkup("Details <click>", "<p>Example:</p>"+code),
+	)
+
+	assert.Equal(
+		t,
+		`<ac:structured-macro ac:name="expand">`+
+			`<ac:rich-text-body><p>x</p></ac:rich-text-body>`+
+			`</ac:structured-macro>`,
+		api.ExpandMacroMarkup("", "<p>x</p>"),
+	)
+}
+
+func TestSmartLinkMarkup(t *testing.T) {
+	api := NewAPI("http://confluence.example.com", "user", "password")
+
+	assert.Equal(
+		t,
+		`<a href="https://github.com/kovetskiy/mark?tab=readme&amp;x=1"`+
+			` data-card-appearance="block">`+
+			`https://github.com/kovetskiy/mark?tab=readme&amp;x=1</a>`,
+		api.SmartLinkMarkup(
+			"https://github.com/kovetskiy/mark?tab=readme&x=1",
+			"card",
+		),
+	)
+
+	assert.Equal(
+		t,
+		`<a href="https://example.com" data-card-appearance="embed">`+
+			`https://example.com</a>`,
+		api.SmartLinkMarkup("https://example.com", "embed"),
+	)
+
+	assert.Equal(
+		t,
+		`<a href="mailto:jane@example.com">mailto:jane@example.com</a>`,
+		api.SmartLinkMarkup("mailto:jane@example.com", "card"),
+	)
+
+	assert.Equal(
+		t,
+		`<a href="https://example.com">https://example.com</a>`,
+		api.SmartLinkMarkup("https://example.com", "banner"),
+	)
+}
@@ -0,0 +1,388 @@
+package confluence
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/reconquest/karma-go"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	reCDATA      = regexp.MustCompile(`(?s)<!\[CDATA\[(.*?)\]\]>`)
+	reWhitespace = regexp.MustCompile(`\s+`)
+)
+
+// storageToMarkdown converts a page body in Confluence storage format to
+// markdown. It covers the markup mark itself produces; unknown macros and
+// elements are reduced to their text content. Attachments are linked
+// relative to attachmentDir.
+func storageToMarkdown(storage string, attachmentDir string) (string, error) {
+	// The HTML parser treats CDATA sections as comments ending at the first
+	// '>', so they are turned into escaped text beforehand.
+	storage = reCDATA.ReplaceAllStringFunc(storage, func(cdata string) string {
+		return html.EscapeString(reCDATA.FindStringSubmatch(cdata)[1])
+	})
+
+	nodes, err := html.ParseFragment(
+		strings.NewReader(storage),
+		&html.Node{
+			Type:     html.ElementNode,
+			Data:     "body",
+			DataAtom: atom.Body,
+		},
+	)
+	if err != nil {
+		return "", karma.Format(err, "unable to parse storage format")
+	}
+
+	converter := &storageConverter{attachmentDir: attachmentDir}
+	for _, node := range nodes {
+		converter.block(node)
+	}
+
+	return strings.TrimSpace(converter.output.String()) + "\n", nil
+}
+
+type storageConverter struct {
+	output        strings.Builder
+	attachmentDir string
+	lists         []string
+}
+
+// block renders a block-level node followed by a blank line.
+func (c *storageConverter) block(node *html.Node) {
+	if node.Type == html.TextNode {
+		if text := strings.TrimSpace(node.Data); text != "" {
+			c.output.WriteString(text + "\n\n")
+		}
+
+		return
+	}
+
+	if node.Type != html.ElementNode {
+		return
+	}
+
+	switch node.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level := int(node.Data[1] - '0')
+		c.output.WriteString(
+			strings.Repeat("#", level) + " " + c.inline(node) + "\n\n",
+		)
+
+	case "p":
+		if text := c.inline(node); strings.TrimSpace(text) != "" {
+			c.output.WriteString(text + "\n\n")
+		}
+
+	case "ul", "ol":
+		c.list(node)
+		if len(c.lists) == 0 {
+			c.output.WriteString("\n")
+		}
+
+	case "blockquote":
+		inner := &storageConverter{attachmentDir: c.attachmentDir}
+		inner.children(node)
+
+		for _, line := range strings.Split(strings.TrimSpace(inner.output.String()), "\n") {
+			c.output.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+		}
+		c.output.WriteString("\n")
+
+	case "pre":
+		c.code("", textContent(node))
+
+	case "hr":
+		c.output.WriteString("---\n\n")
+
+	case "table":
+		c.table(node)
+
+	case "ac:structured-macro":
+		c.macro(node)
+
+	case "ac:image":
+		c.output.WriteString(c.image(node) + "\n\n")
+
+	default:
+		c.children(node)
+	}
+}
+
+func (c *storageConverter) children(node *html.Node) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		c.block(child)
+	}
+}
+
+func (c *storageConverter) macro(node *html.Node) {
+	switch attribute(node, "ac:name") {
+	case "code", "noformat":
+		c.code(
+			macroParameter(node, "language"),
+			textContent(findElement(node, "ac:plain-text-body")),
+		)
+
+	default:
+		body := findElement(node, "ac:rich-text-body")
+		if body != nil {
+			c.children(body)
+		}
+	}
+}
+
+func (c *storageConverter) code(language, text string) {
+	c.output.WriteString(
+		"```" + language + "\n" + strings.TrimSuffix(text, "\n") + "\n```\n\n",
+	)
+}
+
+func (c *storageConverter) list(node *html.Node) {
+	c.lists = append(c.lists, node.Data)
+	defer func() {
+		c.lists = c.lists[:len(c.lists)-1]
+	}()
+
+	indent := strings.Repeat("  ", len(c.lists)-1)
+
+	index := 0
+	for item := node.FirstChild; item != nil; item = item.NextSibling {
+		if item.Type != html.ElementNode || item.Data != "li" {
+			continue
+		}
+
+		index++
+
+		marker := "-"
+		if node.Data == "ol" {
+			marker = fmt.Sprintf("%d.", index)
+		}
+
+		var text strings.Builder
+		var nested []*html.Node
+		for child := item.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == html.ElementNode &&
+				(child.Data == "ul" || child.Data == "ol") {
+				nested = append(nested, child)
+				continue
+			}
+
+			text.WriteString(c.inlineNode(child))
+		}
+
+		c.output.WriteString(
+			indent + marker + " " + strings.TrimSpace(text.String()) + "\n",
+		)
+
+		for _, child := range nested {
+			c.list(child)
+		}
+	}
+}
+
+func (c *storageConverter) table(node *html.Node) {
+	rows := [][]string{}
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+
+			if child.Data != "tr" {
+				walk(child)
+				continue
+			}
+
+			row := []string{}
+			for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+				if cell.Type == html.ElementNode &&
+					(cell.Data == "th" || cell.Data == "td") {
+					row = append(row, strings.ReplaceAll(
+						strings.TrimSpace(c.inline(cell)), "|", `\|`,
+					))
+				}
+			}
+
+			rows = append(rows, row)
+		}
+	}
+
+	walk(node)
+
+	if len(rows) == 0 {
+		return
+	}
+
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+
+	for i, row := range rows {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+
+		c.output.WriteString("| " + strings.Join(row, " | ") + " |\n")
+
+		if i == 0 {
+			c.output.WriteString(
+				"|" + strings.Repeat(" --- |", columns) + "\n",
+			)
+		}
+	}
+
+	c.output.WriteString("\n")
+}
+
+// inline renders the children of node as inline markdown.
+func (c *storageConverter) inline(node *html.Node) string {
+	var text strings.Builder
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		text.WriteString(c.inlineNode(child))
+	}
+
+	return strings.TrimSpace(text.String())
+}
+
+func (c *storageConverter) inlineNode(node *html.Node) string {
+	switch node.Type {
+	case html.TextNode:
+		return reWhitespace.ReplaceAllString(node.Data, " ")
+	case html.ElementNode:
+	default:
+		return ""
+	}
+
+	switch node.Data {
+	case "strong", "b":
+		return "**" + c.inline(node) + "**"
+	case "em", "i":
+		return "_" + c.inline(node) + "_"
+	case "code":
+		return "`" + textContent(node) + "`"
+	case "br":
+		return "\n"
+	case "a":
+		return "[" + c.inline(node) + "](" + attribute(node, "href") + ")"
+	case "ac:image":
+		return c.image(node)
+	case "ac:link":
+		return c.link(node)
+	case "ac:structured-macro":
+		if body := findElement(node, "ac:rich-text-body"); body != nil {
+			return c.inline(body)
+		}
+		return ""
+	default:
+		return c.inline(node)
+	}
+}
+
+func (c *storageConverter) image(node *html.Node) string {
+	alt := attribute(node, "ac:alt")
+
+	if attachment := findElement(node, "ri:attachment"); attachment != nil {
+		return "![" + alt + "](" + path.Join(
+			c.attachmentDir,
+			attribute(attachment, "ri:filename"),
+		) + ")"
+	}
+
+	if link := findElement(node, "ri:url"); link != nil {
+		return "![" + alt + "](" + attribute(link, "ri:value") + ")"
+	}
+
+	return ""
+}
+
+func (c *storageConverter) link(node *html.Node) string {
+	text := ""
+	if body := findElement(node, "ac:link-body"); body != nil {
+		text = c.inline(body)
+	} else if body := findElement(node, "ac:plain-text-link-body"); body != nil {
+		text = textContent(body)
+	}
+
+	target := ""
+	if page := findElement(node, "ri:page"); page != nil {
+		target = attribute(page, "ri:content-title")
+	} else if attachment := findElement(node, "ri:attachment"); attachment != nil {
+		target = path.Join(c.attachmentDir, attribute(attachment, "ri:filename"))
+	}
+
+	if anchor := attribute(node, "ac:anchor"); anchor != "" {
+		target += "#" + anchor
+	}
+
+	if text == "" {
+		text = target
+	}
+
+	return "[" + text + "](" + target + ")"
+}
+
+// textContent returns the text of node and all its descendants.
+func textContent(node *html.Node) string {
+	if node == nil {
+		return ""
+	}
+
+	var text strings.Builder
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		if node.Type == html.TextNode {
+			text.WriteString(node.Data)
+		}
+
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+
+	walk(node)
+
+	return text.String()
+}
+
+func findElement(node *html.Node, name string) *html.Node {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.Data == name {
+			return child
+		}
+
+		if found := findElement(child, name); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+func macroParameter(node *html.Node, name string) string {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode &&
+			child.Data == "ac:parameter" &&
+			attribute(child, "ac:name") == name {
+			return textContent(child)
+		}
+	}
+
+	return ""
+}
+
+func attribute(node *html.Node, name string) string {
+	for _, attr := range node.Attr {
+		if attr.Key == name {
+			return attr.Val
+		}
+	}
+
+	return ""
+}
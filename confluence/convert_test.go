@@ -0,0 +1,32 @@
+package confluence
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorageToMarkdown(t *testing.T) {
+	markdown, err := storageToMarkdown(
+		`<h2>Usage</h2>`+
+			`<p>Run <code>mark</code> with <strong>care</strong>.</p>`+
+			`<ul><li>one<ul><li>nested</li></ul></li><li>two</li></ul>`+
+			`<ac:structured-macro ac:name="code">`+
+			`<ac:parameter ac:name="language">bash</ac:parameter>`+
+			`<ac:plain-text-body><![CDATA[mark -f a.md > out]]></ac:plain-text-body>`+
+			`</ac:structured-macro>`+
+			`<table><tbody><tr><th>A</th><th>B</th></tr>`+
+			`<tr><td>1</td><td>2</td></tr></tbody></table>`+
+			`<p><ac:link><ri:page ri:content-title="Other" />`+
+			`<ac:plain-text-link-body><![CDATA[see other]]></ac:plain-text-link-body>`+
+			`</ac:link></p>`,
+		"Page",
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, "## Usage\n\n"+
+		"Run `mark` with **care**.\n\n"+
+		"- one\n  - nested\n- two\n\n"+
+		"```bash\nmark -f a.md > out\n```\n\n"+
+		"| A | B |\n| --- | --- |\n| 1 | 2 |\n\n"+
+		"[see other](Other)\n", markdown)
+}
@@ -0,0 +1,115 @@
+package confluence
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/kovetskiy/gopencils"
+	"github.com/reconquest/pkg/log"
+)
+
+// RecordedRequest is a request RecordingTransport didn't send.
+type RecordedRequest struct {
+	Method  string
+	URL     string
+	Payload []byte
+}
+
+// RecordingTransport is an http.RoundTripper for dry runs: requests that
+// would change anything are logged and recorded instead of being sent, and
+// answered with a synthetic success response that echoes JSON payloads.
+// Reads are sent using Base, or answered with 404 (Not Found) if Base is nil.
+type RecordingTransport struct {
+	Base http.RoundTripper
+
+	mutex    sync.Mutex
+	requests []RecordedRequest
+}
+
+// Requests returns the requests recorded so far.
+func (transport *RecordingTransport) Requests() []RecordedRequest {
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+
+	return append([]RecordedRequest{}, transport.requests...)
+}
+
+func (transport *RecordingTransport) RoundTrip(
+	request *http.Request,
+) (*http.Response, error) {
+	switch request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		if transport.Base != nil {
+			return transport.Base.RoundTrip(request)
+		}
+
+		return syntheticResponse(request, http.StatusNotFound, nil), nil
+	}
+
+	var payload []byte
+	if request.Body != nil {
+		var err error
+
+		payload, err = io.ReadAll(request.Body)
+		_ = request.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	log.Infof(nil, "dry run: %s %s", request.Method, request.URL)
+
+	transport.mutex.Lock()
+	transport.requests = append(transport.requests, RecordedRequest{
+		Method:  request.Method,
+		URL:     request.URL.String(),
+		Payload: payload,
+	})
+	transport.mutex.Unlock()
+
+	body := []byte(`{}`)
+	if json.Valid(payload) {
+		body = payload
+	}
+
+	return syntheticResponse(request, http.StatusOK, body), nil
+}
+
+func syntheticResponse(
+	request *http.Request,
+	status int,
+	body []byte,
+) *http.Response {
+	return &http.Response{
+		Status:     strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode: status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    request,
+	}
+}
+
+// DryRun makes the API record the requests that would change anything
+// instead of sending them and returns the transport holding the records.
+// Reads are still sent to Confluence.
+func (api *API) DryRun() *RecordingTransport {
+	transport := &RecordingTransport{Base: api.rest.Api.Client.Transport}
+	if transport.Base == nil {
+		transport.Base = http.DefaultTransport
+	}
+
+	for _, resource := range []*gopencils.Resource{api.rest, api.json} {
+		client := *resource.Api.Client
+		client.Transport = transport
+		resource.Api.Client = &client
+	}
+
+	return transport
+}
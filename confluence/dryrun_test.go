@@ -0,0 +1,36 @@
+package confluence
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRunCreatePage(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request: %s %s", r.Method, r.URL)
+	})
+
+	recorder := api.DryRun()
+
+	page, err := api.CreatePage("DOC", "page", nil, "Preview", "<p>a</p>")
+	assert.NoError(t, err)
+	assert.Equal(t, "Preview", page.Title)
+
+	requests := recorder.Requests()
+	assert.Len(t, requests, 1)
+	assert.Equal(t, http.MethodPost, requests[0].Method)
+	assert.Contains(t, requests[0].URL, "/rest/api/content/")
+
+	var payload struct {
+		Title string `json:"title"`
+		Space struct {
+			Key string `json:"key"`
+		} `json:"space"`
+	}
+	assert.NoError(t, json.Unmarshal(requests[0].Payload, &payload))
+	assert.Equal(t, "Preview", payload.Title)
+	assert.Equal(t, "DOC", payload.Space.Key)
+}
@@ -0,0 +1,371 @@
+package confluence
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/reconquest/karma-go"
+	"github.com/reconquest/pkg/log"
+)
+
+// exportConcurrency is the number of pages ExportSpaceToMarkdown exports at
+// the same time.
+const exportConcurrency = 4
+
+// ExportSpaceToMarkdown converts all pages of the given space to markdown
+// files in outDir. The directory tree mirrors the page hierarchy: every page
+// is written as "<title>.md" into the directory of its parent, and its
+// attachments are downloaded into a directory named after the page next to
+// it.
+func (api *API) ExportSpaceToMarkdown(space, outDir string) error {
+	pages, err := api.listSpacePages(space)
+	if err != nil {
+		return karma.Format(err, "unable to list pages in space %q", space)
+	}
+
+	var (
+		mutex sync.Mutex
+		errs  []error
+	)
+
+	parallel(len(pages), exportConcurrency, func(i int) {
+		err := api.exportPage(space, pages[i], outDir)
+		if err != nil {
+			mutex.Lock()
+			errs = append(errs, err)
+			mutex.Unlock()
+		}
+	})
+
+	if len(errs) > 0 {
+		return karma.Format(errs[0], "unable to export space %q", space)
+	}
+
+	return nil
+}
+
+func (api *API) exportPage(space string, page PageInfo, outDir string) error {
+	dir := outDir
+	for _, ancestor := range page.Ancestors {
+		dir = filepath.Join(dir, exportFilename(ancestor.Title))
+	}
+
+	name := exportFilename(page.Title)
+
+	log.Infof(nil, "exporting page %q to %s", page.Title, dir)
+
+	storage, err := api.getPageStorage(page.ID)
+	if err != nil {
+		return karma.Format(err, "unable to retrieve body of page %q", page.Title)
+	}
+
+	markdown, err := storageToMarkdown(storage, name)
+	if err != nil {
+		return karma.Format(err, "unable to convert page %q", page.Title)
+	}
+
+	header := "<!-- Space: " + space + " -->\n"
+	if len(page.Ancestors) > 0 {
+		header += "<!-- Parent: " +
+			page.Ancestors[len(page.Ancestors)-1].Title + " -->\n"
+	}
+	header += "<!-- Title: " + page.Title + " -->\n\n"
+
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return karma.Format(err, "unable to create directory %q", dir)
+	}
+
+	err = os.WriteFile(
+		filepath.Join(dir, name+".md"),
+		[]byte(header+markdown),
+		0o644,
+	)
+	if err != nil {
+		return karma.Format(err, "unable to write page %q", page.Title)
+	}
+
+	attachments, err := api.GetAttachments(page.ID)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to retrieve attachments of page %q",
+			page.Title,
+		)
+	}
+
+	for _, attachment := range attachments {
+		err := api.exportAttachment(attachment, filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (api *API) exportAttachment(attachment AttachmentInfo, dir string) error {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return karma.Format(err, "unable to create directory %q", dir)
+	}
+
+	return api.downloadAttachmentToFile(
+		attachment,
+		filepath.Join(dir, exportFilename(attachment.Filename)),
+	)
+}
+
+// downloadAttachmentToFile writes the content of the given attachment to a
+// file at path.
+func (api *API) downloadAttachmentToFile(attachment AttachmentInfo, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return karma.Format(err, "unable to create file %q", path)
+	}
+
+	err = api.DownloadAttachment(attachment, file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// DownloadPageAttachments downloads all attachments of the given page into
+// dir, which is created if needed, and returns the number of downloaded
+// attachments. Files are named after the attachments; if several attachments
+// end up with the same file name, a counter is added to all but the first,
+// e.g. "image-1.png".
+func (api *API) DownloadPageAttachments(pageID, dir string) (int, error) {
+	attachments, err := api.GetAttachments(pageID)
+	if err != nil {
+		return 0, karma.Format(
+			err,
+			"unable to retrieve attachments of page %q",
+			pageID,
+		)
+	}
+
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return 0, karma.Format(err, "unable to create directory %q", dir)
+	}
+
+	paths := make([]string, len(attachments))
+	taken := map[string]bool{}
+	for i, attachment := range attachments {
+		name := uniqueFilename(exportFilename(attachment.Filename), taken)
+		taken[strings.ToLower(name)] = true
+
+		paths[i] = filepath.Join(dir, name)
+	}
+
+	var (
+		mutex      sync.Mutex
+		downloaded int
+		errs       []error
+	)
+
+	parallel(len(attachments), exportConcurrency, func(i int) {
+		err := api.downloadAttachmentToFile(attachments[i], paths[i])
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if err != nil {
+			errs = append(errs, karma.Format(
+				err,
+				"unable to download attachment %q",
+				attachments[i].Filename,
+			))
+			return
+		}
+
+		downloaded++
+	})
+
+	if len(errs) > 0 {
+		return downloaded, errs[0]
+	}
+
+	return downloaded, nil
+}
+
+// uniqueFilename returns name, or name with a counter added before its
+// extension if it's already taken. Names are compared case-insensitively
+// since not every file system tells them apart.
+func uniqueFilename(name string, taken map[string]bool) string {
+	if !taken[strings.ToLower(name)] {
+		return name
+	}
+
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s-%d%s", base, i, ext)
+		if !taken[strings.ToLower(candidate)] {
+			return candidate
+		}
+	}
+}
+
+// exportFilename makes a page or attachment title safe to use as a file
+// name. Leading dots are replaced as well, so titles like ".." can't refer
+// to a directory outside of the export and files aren't hidden.
+func exportFilename(title string) string {
+	name := strings.NewReplacer(
+		"/", "_",
+		`\`, "_",
+		"\x00", "",
+	).Replace(title)
+
+	trimmed := strings.TrimLeft(name, ".")
+	name = strings.Repeat("_", len(name)-len(trimmed)) + trimmed
+
+	if name == "" {
+		return "_"
+	}
+
+	return name
+}
+
+// DumpStorage writes the body of the given page in storage format to a file
+// at path, indented for reading. Missing parent directories are created. The
+// body is written as it is if it can't be parsed as XML.
+func (api *API) DumpStorage(pageID, path string) error {
+	storage, err := api.getPageStorage(pageID)
+	if err != nil {
+		return karma.Format(err, "unable to retrieve body of page %q", pageID)
+	}
+
+	indented, err := indentStorage(storage)
+	if err != nil {
+		log.Warningf(err, "unable to indent body of page %q", pageID)
+
+		indented = storage
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return karma.Format(err, "unable to create directory %q", filepath.Dir(path))
+	}
+
+	err = os.WriteFile(path, []byte(indented), 0o644)
+	if err != nil {
+		return karma.Format(err, "unable to write file %q", path)
+	}
+
+	return nil
+}
+
+// indentStorage puts every element of the given storage format document on
+// its own line, indented by its depth. Namespace prefixes like "ac:" are kept
+// as they are.
+func indentStorage(storage string) (string, error) {
+	decoder := xml.NewDecoder(strings.NewReader(storage))
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+
+	var (
+		buffer  bytes.Buffer
+		depth   int
+		pending bool
+	)
+
+	newline := func(level int) {
+		if buffer.Len() > 0 {
+			buffer.WriteString("\n")
+		}
+
+		buffer.WriteString(strings.Repeat("  ", level))
+	}
+
+	closePending := func() {
+		if pending {
+			buffer.WriteString(">")
+			pending = false
+		}
+	}
+
+	for {
+		token, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			closePending()
+			newline(depth)
+			buffer.WriteString("<" + storageName(token.Name))
+			for _, attr := range token.Attr {
+				buffer.WriteString(
+					" " + storageName(attr.Name) + `="` +
+						attributeEscaper.Replace(attr.Value) + `"`,
+				)
+			}
+
+			pending = true
+			depth++
+
+		case xml.EndElement:
+			depth--
+
+			if pending {
+				buffer.WriteString(" />")
+				pending = false
+			} else {
+				newline(depth)
+				buffer.WriteString("</" + storageName(token.Name) + ">")
+			}
+
+		case xml.CharData:
+			text := strings.Trim(string(token), " \t\r\n")
+			if text == "" {
+				continue
+			}
+
+			closePending()
+			newline(depth)
+			buffer.WriteString(textEscaper.Replace(text))
+
+		case xml.Comment:
+			closePending()
+			newline(depth)
+			buffer.WriteString("<!--" + string(token) + "-->")
+		}
+	}
+
+	buffer.WriteString("\n")
+
+	return buffer.String(), nil
+}
+
+var (
+	textEscaper      = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	attributeEscaper = strings.NewReplacer(
+		"&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;",
+	)
+)
+
+func storageName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+
+	return name.Space + ":" + name.Local
+}
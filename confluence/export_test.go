@@ -0,0 +1,180 @@
+package confluence
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportSpaceToMarkdown(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/api/content":
+			_, _ = io.WriteString(w, `{"results": [
+				{"id": "1", "title": "Home"},
+				{"id": "2", "title": "Guide", "ancestors": [{"id": "1", "title": "Home"}]}
+			]}`)
+
+		case "/rest/api/content/1":
+			_, _ = io.WriteString(w, `{"body": {"storage": {"value": "<p>Welcome</p>"}}}`)
+
+		case "/rest/api/content/2":
+			_, _ = io.WriteString(w, `{"body": {"storage": {"value":
+				"<h1>Setup</h1><p><ac:image><ri:attachment ri:filename=\"diagram.png\" /></ac:image></p>"
+			}}}`)
+
+		case "/rest/api/content/1/child/attachment":
+			_, _ = io.WriteString(w, `{"results": []}`)
+
+		case "/rest/api/content/2/child/attachment":
+			_, _ = io.WriteString(w, `{"results": [{
+				"id": "att1",
+				"title": "diagram.png",
+				"_links": {"download": "/download/attachments/2/diagram.png"}
+			}]}`)
+
+		case "/download/attachments/2/diagram.png":
+			_, _ = io.WriteString(w, "png data")
+
+		default:
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+	})
+
+	dir := t.TempDir()
+
+	assert.NoError(t, api.ExportSpaceToMarkdown("DOC", dir))
+
+	home, err := os.ReadFile(filepath.Join(dir, "Home.md"))
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		"<!-- Space: DOC -->\n<!-- Title: Home -->\n\nWelcome\n",
+		string(home),
+	)
+
+	guide, err := os.ReadFile(filepath.Join(dir, "Home", "Guide.md"))
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		"<!-- Space: DOC -->\n<!-- Parent: Home -->\n<!-- Title: Guide -->\n\n"+
+			"# Setup\n\n![](Guide/diagram.png)\n",
+		string(guide),
+	)
+
+	attachment, err := os.ReadFile(
+		filepath.Join(dir, "Home", "Guide", "diagram.png"),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, "png data", string(attachment))
+}
+
+func TestExportFilename(t *testing.T) {
+	assert.Equal(t, "a_b_c", exportFilename(`a/b\c`))
+	assert.Equal(t, "__", exportFilename(".."))
+	assert.Equal(t, "_", exportFilename("."))
+	assert.Equal(t, "_", exportFilename(""))
+	assert.Equal(t, "__hidden", exportFilename("..hidden"))
+	assert.Equal(t, "v1.0..2", exportFilename("v1.0..2"))
+}
+
+func TestExportSpaceToMarkdownStaysInDirectory(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/api/content":
+			_, _ = io.WriteString(w, `{"results": [
+				{"id": "1", "title": ".."},
+				{"id": "2", "title": "..", "ancestors": [{"id": "1", "title": ".."}]}
+			]}`)
+
+		case "/rest/api/content/1", "/rest/api/content/2":
+			_, _ = io.WriteString(w, `{"body": {"storage": {"value": "<p>a</p>"}}}`)
+
+		case "/rest/api/content/1/child/attachment",
+			"/rest/api/content/2/child/attachment":
+			_, _ = io.WriteString(w, `{"results": []}`)
+
+		default:
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+	})
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "export")
+
+	assert.NoError(t, api.ExportSpaceToMarkdown("DOC", dir))
+
+	assert.FileExists(t, filepath.Join(dir, "__.md"))
+	assert.FileExists(t, filepath.Join(dir, "__", "__.md"))
+
+	entries, err := os.ReadDir(root)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestDumpStorage(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/content/1", r.URL.Path)
+
+		_, _ = io.WriteString(w, `{"body": {"storage": {"value": `+
+			`"<p>Hello&nbsp;<strong>world</strong></p>`+
+			`<ac:image><ri:attachment ri:filename=\"a.png\" /></ac:image>"`+
+			`}}}`)
+	})
+
+	path := filepath.Join(t.TempDir(), "dump", "page.xml")
+
+	assert.NoError(t, api.DumpStorage("1", path))
+
+	dump, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "<p>\n"+
+		"  Hello\u00a0\n"+
+		"  <strong>\n"+
+		"    world\n"+
+		"  </strong>\n"+
+		"</p>\n"+
+		"<ac:image>\n"+
+		"  <ri:attachment ri:filename=\"a.png\" />\n"+
+		"</ac:image>\n", string(dump))
+}
+
+func TestDownloadPageAttachments(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/api/content/2/child/attachment":
+			_, _ = io.WriteString(w, `{"results": [
+				{"id": "att1", "title": "a/b.png", "_links": {"download": "/download/1"}},
+				{"id": "att2", "title": "a_b.png", "_links": {"download": "/download/2"}},
+				{"id": "att3", "title": "notes.txt", "_links": {"download": "/download/3"}}
+			]}`)
+
+		case "/download/1", "/download/2", "/download/3":
+			_, _ = io.WriteString(w, "data "+path.Base(r.URL.Path))
+
+		default:
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+	})
+
+	dir := t.TempDir()
+
+	count, err := api.DownloadPageAttachments("2", dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	for name, content := range map[string]string{
+		"a_b.png":   "data 1",
+		"a_b-1.png": "data 2",
+		"notes.txt": "data 3",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		assert.NoError(t, err)
+		assert.Equal(t, content, string(data))
+	}
+}
@@ -0,0 +1,95 @@
+package confluence
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// IsFavourited reports whether the current user has favourited, or saved
+// for later, the given page.
+func (api *API) IsFavourited(pageID string) (bool, error) {
+	resp, err := api.favouriteRequest(http.MethodGet, pageID)
+	if err != nil {
+		return false, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		resp.Body.Close()
+		return true, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return false, nil
+	default:
+		return false, newErrorStatus(resp)
+	}
+}
+
+// SetFavourite adds the given page to or removes it from the favourites of
+// the current user.
+func (api *API) SetFavourite(pageID string, on bool) error {
+	method := http.MethodDelete
+	if on {
+		method = http.MethodPut
+	}
+
+	resp, err := api.favouriteRequest(method, pageID)
+	if err != nil {
+		return err
+	}
+
+	// removing a page that isn't a favourite is fine
+	if resp.StatusCode == http.StatusNotFound && !on {
+		resp.Body.Close()
+		return nil
+	}
+
+	if resp.StatusCode != http.StatusOK &&
+		resp.StatusCode != http.StatusNoContent {
+		return newErrorStatus(resp)
+	}
+
+	resp.Body.Close()
+
+	return nil
+}
+
+// favouriteRequest sends a request to the favourite relation between the
+// current user and the given page. Cloud has it in the REST API, while
+// Server only provides it as an experimental API.
+func (api *API) favouriteRequest(method, pageID string) (*http.Response, error) {
+	target := api.instanceURL(
+		"/rest/experimental/relation/user/current/favourite/toContent/" + pageID,
+	)
+	if api.isCloud() {
+		target = api.instanceURL(
+			"/rest/api/relation/favourite/from/user/current/to/content/" + pageID,
+		)
+	}
+
+	reqFn := func() (*http.Response, error) {
+		request, err := http.NewRequest(method, target.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		api.authorize(request)
+		request.Header.Set("Accept", "application/json")
+		request.Header.Set("X-Atlassian-Token", "no-check")
+
+		return api.rest.Api.Client.Do(request)
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		time.Sleep(1 * time.Second)
+		return api.favouriteRequest(method, pageID)
+	}
+
+	return resp, nil
+}
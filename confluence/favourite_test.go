@@ -0,0 +1,60 @@
+package confluence
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetFavourite(t *testing.T) {
+	var (
+		mutex      sync.Mutex
+		favourited bool
+	)
+
+	handler := func(path string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			assert.Equal(t, path, r.URL.Path)
+
+			switch r.Method {
+			case http.MethodPut:
+				favourited = true
+			case http.MethodDelete:
+				favourited = false
+			}
+
+			if !favourited {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+		}
+	}
+
+	for _, api := range []*API{
+		newTestAPI(t, handler("/rest/experimental/relation/user/current/favourite/toContent/42")),
+		newCloudTestAPI(t, handler("/wiki/rest/api/relation/favourite/from/user/current/to/content/42")),
+	} {
+		on, err := api.IsFavourited("42")
+		assert.NoError(t, err)
+		assert.False(t, on)
+
+		assert.NoError(t, api.SetFavourite("42", true))
+
+		on, err = api.IsFavourited("42")
+		assert.NoError(t, err)
+		assert.True(t, on)
+
+		assert.NoError(t, api.SetFavourite("42", false))
+
+		on, err = api.IsFavourited("42")
+		assert.NoError(t, err)
+		assert.False(t, on)
+	}
+}
@@ -0,0 +1,58 @@
+package confluence
+
+import (
+	"html"
+	"strings"
+
+	"github.com/reconquest/karma-go"
+)
+
+// GenerateIndex returns storage markup of a nested list linking to every page
+// below the page with the given ID, down to IndexDepth levels. The result can
+// be published as the body of a landing page.
+func (api *API) GenerateIndex(rootID string) (string, error) {
+	var index strings.Builder
+
+	err := api.writeIndex(&index, rootID, 1)
+	if err != nil {
+		return "", err
+	}
+
+	return index.String(), nil
+}
+
+func (api *API) writeIndex(index *strings.Builder, pageID string, depth int) error {
+	if depth > api.IndexDepth {
+		return nil
+	}
+
+	children, err := api.GetChildPages(pageID)
+	if err != nil {
+		return karma.Format(err, "unable to list children of page %q", pageID)
+	}
+
+	if len(children) == 0 {
+		return nil
+	}
+
+	index.WriteString("<ul>")
+
+	for _, child := range children {
+		index.WriteString(
+			`<li><ac:link><ri:page ri:content-title="` +
+				html.EscapeString(child.Title) +
+				`" /></ac:link>`,
+		)
+
+		err := api.writeIndex(index, child.ID, depth+1)
+		if err != nil {
+			return err
+		}
+
+		index.WriteString("</li>")
+	}
+
+	index.WriteString("</ul>")
+
+	return nil
+}
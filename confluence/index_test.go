@@ -0,0 +1,48 @@
+package confluence
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateIndex(t *testing.T) {
+	children := map[string]string{
+		"1": `[{"id": "2", "title": "Guides"}, {"id": "3", "title": "R&D"}]`,
+		"2": `[{"id": "4", "title": "Install"}]`,
+		"3": `[]`,
+		"4": `[{"id": "5", "title": "Too deep"}]`,
+	}
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		var id string
+		for key := range children {
+			if r.URL.Path == "/rest/api/content/"+key+"/child/page" {
+				id = key
+			}
+		}
+
+		if id == "" {
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+
+		_, _ = io.WriteString(w, `{"results": `+children[id]+`}`)
+	})
+
+	api.IndexDepth = 2
+
+	index, err := api.GenerateIndex("1")
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		`<ul>`+
+			`<li><ac:link><ri:page ri:content-title="Guides" /></ac:link>`+
+			`<ul><li><ac:link><ri:page ri:content-title="Install" /></ac:link></li></ul>`+
+			`</li>`+
+			`<li><ac:link><ri:page ri:content-title="R&amp;D" /></ac:link></li>`+
+			`</ul>`,
+		index,
+	)
+}
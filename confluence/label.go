@@ -0,0 +1,347 @@
+package confluence
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/reconquest/karma-go"
+	"github.com/reconquest/pkg/log"
+)
+
+// PropertyLabels stores the global labels mark has set on a page, so labels
+// added by humans are never removed by ReconcileLabels.
+const PropertyLabels = "mark:labels"
+
+// LabelsProperty is the value of the PropertyLabels content property.
+type LabelsProperty struct {
+	Labels []string `json:"labels"`
+}
+
+// ReconcileLabels makes the global labels of the page contain exactly the
+// desired labels as far as mark is concerned: missing labels are added and
+// labels mark has set previously but are no longer desired are removed.
+// Labels added by anyone else are left untouched.
+//
+// Pages published before mark tracked its labels have no PropertyLabels
+// property; for them every global label is considered to be set by mark.
+func (api *API) ReconcileLabels(page *PageInfo, desired []string) error {
+	labelInfo, err := api.GetPageLabels(page, "global")
+	if err != nil {
+		return karma.Format(err, "unable to retrieve page labels")
+	}
+
+	var owned LabelsProperty
+
+	found, err := api.GetContentProperty(page.ID, PropertyLabels, &owned)
+	if err != nil {
+		return karma.Format(err, "unable to retrieve labels set by mark")
+	}
+
+	current := []string{}
+	for _, label := range labelInfo.Labels {
+		current = append(current, label.Name)
+	}
+
+	if !found {
+		owned.Labels = current
+	}
+
+	add := subtractLabels(desired, current)
+
+	remove := []string{}
+	for _, label := range subtractLabels(owned.Labels, desired) {
+		if containsLabel(current, label) {
+			remove = append(remove, label)
+		}
+	}
+
+	log.Debugf(nil, "page labels: %v", current)
+	log.Debugf(nil, "labels set by mark: %v", owned.Labels)
+	log.Debugf(nil, "labels to add: %v", add)
+	log.Debugf(nil, "labels to remove: %v", remove)
+
+	if len(add) > 0 {
+		_, err = api.AddPageLabels(page, add)
+		if err != nil {
+			return karma.Format(err, "error adding labels")
+		}
+	}
+
+	if len(remove) > 0 {
+		err = api.DeletePageLabels(page, remove)
+		if err != nil {
+			return karma.Format(err, "error deleting labels")
+		}
+	}
+
+	if desired == nil {
+		desired = []string{}
+	}
+
+	err = api.SetContentProperty(
+		page.ID,
+		PropertyLabels,
+		LabelsProperty{Labels: desired},
+	)
+	if err != nil {
+		return karma.Format(err, "unable to store labels set by mark")
+	}
+
+	return nil
+}
+
+// SyncLabels makes the global labels of the page exactly the desired labels
+// and returns them. Unlike ReconcileLabels it also removes global labels
+// added by humans. Labels with other prefixes, like "my" or "team", are set
+// by other integrations and left untouched.
+func (api *API) SyncLabels(page *PageInfo, desired []string) ([]string, error) {
+	labelInfo, err := api.GetPageLabels(page, "global")
+	if err != nil {
+		return nil, karma.Format(err, "unable to retrieve page labels")
+	}
+
+	current := []string{}
+	for _, label := range labelInfo.Labels {
+		current = append(current, label.Name)
+	}
+
+	add := []string{}
+	for _, label := range subtractLabels(desired, current) {
+		if !containsLabel(add, label) {
+			add = append(add, label)
+		}
+	}
+
+	remove := subtractLabels(current, desired)
+
+	log.Debugf(nil, "labels to add: %v", add)
+	log.Debugf(nil, "labels to remove: %v", remove)
+
+	if len(add) > 0 {
+		_, err = api.AddPageLabels(page, add)
+		if err != nil {
+			return nil, karma.Format(err, "error adding labels")
+		}
+	}
+
+	if len(remove) > 0 {
+		err = api.DeletePageLabels(page, remove)
+		if err != nil {
+			return nil, karma.Format(err, "error deleting labels")
+		}
+	}
+
+	labels := append(subtractLabels(current, remove), add...)
+
+	// Every global label is set by mark now, so ReconcileLabels must not
+	// keep treating the previous ones as added by humans.
+	err = api.SetContentProperty(
+		page.ID,
+		PropertyLabels,
+		LabelsProperty{Labels: labels},
+	)
+	if err != nil {
+		return nil, karma.Format(err, "unable to store labels set by mark")
+	}
+
+	return labels, nil
+}
+
+// DeletePageLabels removes the given labels from the page. Confluence Cloud
+// removes them all in a single request; elsewhere, or if Cloud rejects the
+// batch, they are removed one by one. The deployment, if given, overrides
+// the one of the API for this call.
+func (api *API) DeletePageLabels(
+	page *PageInfo,
+	labels []string,
+	deployment ...Deployment,
+) error {
+	if api.isCloudFor(deployment) && len(labels) > 1 {
+		batched, err := api.deletePageLabelsBatch(page, labels)
+		if err != nil {
+			return err
+		}
+
+		if batched {
+			return nil
+		}
+
+		log.Debugf(nil, "batch label deletion is not supported, deleting one by one")
+	}
+
+	for _, label := range labels {
+		_, err := api.DeletePageLabel(page, label)
+		if err != nil {
+			return karma.Format(err, "error deleting label %q", label)
+		}
+	}
+
+	return nil
+}
+
+// deletePageLabelsBatch removes the given labels in a single request with a
+// name query parameter per label. It reports false if the instance doesn't
+// support it.
+func (api *API) deletePageLabelsBatch(
+	page *PageInfo,
+	labels []string,
+) (bool, error) {
+	reqFn := func() (*http.Response, error) {
+		resource := api.resource("content/"+page.ID+"/label", &LabelInfo{})
+		resource.QueryValues = url.Values{"name": labels}
+
+		request, err := resource.Delete()
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return false, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		time.Sleep(1 * time.Second)
+		return api.deletePageLabelsBatch(page, labels)
+
+	case http.StatusOK, http.StatusNoContent:
+		return true, nil
+
+	case http.StatusBadRequest, http.StatusMethodNotAllowed:
+		_ = resp.Body.Close()
+		return false, nil
+
+	default:
+		return false, newErrorStatus(resp)
+	}
+}
+
+// subtractLabels returns the labels of a which are not in b, comparing them
+// case-insensitively as Confluence does.
+func subtractLabels(a, b []string) []string {
+	result := []string{}
+	for _, label := range a {
+		if !containsLabel(b, label) {
+			result = append(result, label)
+		}
+	}
+
+	return result
+}
+
+func containsLabel(labels []string, label string) bool {
+	return slices.ContainsFunc(labels, func(other string) bool {
+		return strings.EqualFold(label, other)
+	})
+}
+
+// GetAllSpaceLabels returns every label used in the given space mapped to the
+// number of pages and blog posts carrying it.
+func (api *API) GetAllSpaceLabels(space string) (map[string]int, error) {
+	query := map[string]string{
+		"cql":    fmt.Sprintf("space = %q and type in (page, blogpost)", space),
+		"expand": "metadata.labels",
+		"limit":  "100",
+	}
+
+	contents, err := fetchPaged[labeledContent](api, "content/search", query)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int{}
+	for _, content := range contents {
+		for _, label := range content.Metadata.Labels.Labels {
+			counts[label.Name]++
+		}
+	}
+
+	return counts, nil
+}
+
+// labeledContent is a search result expanded with its labels.
+type labeledContent struct {
+	Metadata struct {
+		Labels LabelInfo `json:"labels"`
+	} `json:"metadata"`
+}
+
+// LabelWithOwner is a label of a page along with the user that owns it.
+// Personal labels, i.e. those with the "my" prefix, are owned by the user
+// who added them.
+type LabelWithOwner struct {
+	Label
+
+	// Owner is the account ID on Cloud or the username on Server. It's empty
+	// if Confluence doesn't expose the owner of the label, which is the case
+	// for most labels.
+	Owner string
+}
+
+// GetLabelsWithOwners returns all labels of the given page, regardless of
+// their prefix, with their owners where Confluence exposes them.
+func (api *API) GetLabelsWithOwners(pageID string) ([]LabelWithOwner, error) {
+	results, err := fetchPaged[ownedLabel](
+		api,
+		"content/"+pageID+"/label",
+		map[string]string{
+			"expand": "owner",
+			"limit":  "200",
+		},
+	)
+	if err != nil {
+		return nil, karma.Format(err, "unable to retrieve labels of page %q", pageID)
+	}
+
+	labels := []LabelWithOwner{}
+	for _, result := range results {
+		labels = append(labels, LabelWithOwner{
+			Label: result.Label,
+			Owner: labelOwner(result.Owner),
+		})
+	}
+
+	return labels, nil
+}
+
+type ownedLabel struct {
+	Label
+	Owner json.RawMessage `json:"owner"`
+}
+
+// labelOwner returns the identifier of the owner of a label, which Confluence
+// returns either as a plain username or as a user object.
+func labelOwner(raw json.RawMessage) string {
+	var name string
+	if json.Unmarshal(raw, &name) == nil {
+		return name
+	}
+
+	var user struct {
+		AccountID string `json:"accountId"`
+		Username  string `json:"username"`
+		UserKey   string `json:"userKey"`
+	}
+
+	if json.Unmarshal(raw, &user) != nil {
+		return ""
+	}
+
+	switch {
+	case user.AccountID != "":
+		return user.AccountID
+	case user.Username != "":
+		return user.Username
+	default:
+		return user.UserKey
+	}
+}
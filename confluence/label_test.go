@@ -0,0 +1,248 @@
+package confluence
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAllSpaceLabels(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/content/search", r.URL.Path)
+		assert.Equal(t, `space = "DOC" and type in (page, blogpost)`, r.URL.Query().Get("cql"))
+		assert.Equal(t, "metadata.labels", r.URL.Query().Get("expand"))
+
+		if r.URL.Query().Get("cursor") == "" {
+			_, _ = io.WriteString(w, `{
+				"results": [
+					{"metadata": {"labels": {"results": [{"name": "docs"}, {"name": "api"}]}}}
+				],
+				"_links": {"next": "/rest/api/content/search?cql=space+%3D+%22DOC%22+and+type+in+%28page%2C+blogpost%29&expand=metadata.labels&limit=100&cursor=abc"}
+			}`)
+			return
+		}
+
+		assert.Equal(t, "abc", r.URL.Query().Get("cursor"))
+		_, _ = io.WriteString(w, `{
+			"results": [
+				{"metadata": {"labels": {"results": [{"name": "docs"}]}}}
+			],
+			"_links": {}
+		}`)
+	})
+
+	labels, err := api.GetAllSpaceLabels("DOC")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"docs": 2, "api": 1}, labels)
+}
+
+// labelStore emulates the label and content property endpoints of a page.
+type labelStore struct {
+	labels     []string
+	personal   []string
+	properties *propertyStore
+}
+
+func (s *labelStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.Contains(r.URL.Path, "/property") {
+		s.properties.ServeHTTP(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		var labels []Label
+		_ = json.NewDecoder(r.Body).Decode(&labels)
+		for _, label := range labels {
+			s.labels = append(s.labels, label.Name)
+		}
+
+	case http.MethodDelete:
+		name := r.URL.Query().Get("name")
+		s.labels = slices.DeleteFunc(s.labels, func(label string) bool {
+			return label == name
+		})
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var info LabelInfo
+	for _, name := range s.labels {
+		info.Labels = append(info.Labels, Label{Prefix: "global", Name: name})
+	}
+
+	if r.URL.Query().Get("prefix") != "global" {
+		for _, name := range s.personal {
+			info.Labels = append(info.Labels, Label{Prefix: "my", Name: name})
+		}
+	}
+
+	_ = json.NewEncoder(w).Encode(info)
+}
+
+func TestReconcileLabelsPreservesManualLabels(t *testing.T) {
+	store := &labelStore{properties: newPropertyStore()}
+	api := newTestAPI(t, store.ServeHTTP)
+	page := &PageInfo{ID: "1"}
+
+	assert.NoError(t, api.ReconcileLabels(page, []string{"docs", "draft"}))
+	assert.ElementsMatch(t, []string{"docs", "draft"}, store.labels)
+
+	// added by a human via the web UI
+	store.labels = append(store.labels, "important")
+
+	assert.NoError(t, api.ReconcileLabels(page, []string{"docs"}))
+	assert.ElementsMatch(t, []string{"docs", "important"}, store.labels)
+}
+
+func TestReconcileLabelsWithoutHistory(t *testing.T) {
+	store := &labelStore{
+		labels:     []string{"docs", "stale"},
+		properties: newPropertyStore(),
+	}
+	api := newTestAPI(t, store.ServeHTTP)
+
+	assert.NoError(t, api.ReconcileLabels(&PageInfo{ID: "1"}, []string{"docs", "new"}))
+	assert.ElementsMatch(t, []string{"docs", "new"}, store.labels)
+}
+
+func TestSyncLabels(t *testing.T) {
+	store := &labelStore{
+		labels:     []string{"docs", "stale", "important"},
+		personal:   []string{"todo"},
+		properties: newPropertyStore(),
+	}
+	api := newTestAPI(t, store.ServeHTTP)
+	page := &PageInfo{ID: "1"}
+
+	labels, err := api.SyncLabels(page, []string{"docs", "new", "New"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"docs", "new"}, labels)
+	assert.ElementsMatch(t, []string{"docs", "new"}, store.labels)
+	assert.Equal(t, []string{"todo"}, store.personal)
+
+	// added by a human via the web UI after the sync
+	store.labels = append(store.labels, "important")
+
+	assert.NoError(t, api.ReconcileLabels(page, []string{"docs"}))
+	assert.ElementsMatch(t, []string{"docs", "important"}, store.labels)
+	assert.Equal(t, []string{"todo"}, store.personal)
+}
+
+func TestGetLabelsWithOwners(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/content/1/label", r.URL.Path)
+
+		_, _ = io.WriteString(w, `{
+			"results": [
+				{"prefix": "global", "name": "docs", "id": "10"},
+				{"prefix": "my", "name": "todo", "id": "11", "owner": {"accountId": "557058:jane"}},
+				{"prefix": "my", "name": "later", "id": "12", "owner": "john"}
+			],
+			"_links": {}
+		}`)
+	})
+
+	labels, err := api.GetLabelsWithOwners("1")
+	assert.NoError(t, err)
+	assert.Equal(t, []LabelWithOwner{
+		{Label: Label{ID: "10", Prefix: "global", Name: "docs"}},
+		{Label: Label{ID: "11", Prefix: "my", Name: "todo"}, Owner: "557058:jane"},
+		{Label: Label{ID: "12", Prefix: "my", Name: "later"}, Owner: "john"},
+	}, labels)
+}
+
+func TestDeletePageLabelsBatch(t *testing.T) {
+	var queries []url.Values
+
+	api := newCloudTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodDelete, r.Method)
+		assert.Equal(t, "/wiki/rest/api/content/42/label", r.URL.Path)
+
+		queries = append(queries, r.URL.Query())
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	err := api.DeletePageLabels(&PageInfo{ID: "42"}, []string{"old", "stale"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []url.Values{{"name": {"old", "stale"}}}, queries)
+}
+
+func TestDeletePageLabelsFallback(t *testing.T) {
+	var names []string
+
+	api := newCloudTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if len(r.URL.Query()["name"]) > 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		names = append(names, r.URL.Query().Get("name"))
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	err := api.DeletePageLabels(&PageInfo{ID: "42"}, []string{"old", "stale"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"old", "stale"}, names)
+}
+
+func TestAddLabels(t *testing.T) {
+	var payload []map[string]interface{}
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/rest/api/content/42/label", r.URL.Path)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+
+		_, _ = io.WriteString(w, `{"results": [
+			{"id": "1", "prefix": "global", "name": "docs"},
+			{"id": "2", "prefix": "team", "name": "backend"},
+			{"id": "3", "prefix": "global", "name": "manual"}
+		], "number": 3}`)
+	})
+
+	page := &PageInfo{ID: "42"}
+
+	labels, err := api.AddLabels(page, []Label{
+		{Name: "docs"},
+		{Prefix: "team", Name: "backend"},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, labels.Labels, 3)
+	assert.Equal(t, []map[string]interface{}{
+		{"prefix": "global", "name": "docs"},
+		{"prefix": "team", "name": "backend"},
+	}, payload)
+
+	payload = nil
+
+	for _, label := range []Label{
+		{Name: ""},
+		{Name: "release notes"},
+		{Name: "v1.2"},
+		{Prefix: "public", Name: "docs"},
+	} {
+		_, err = api.AddLabels(page, []Label{{Name: "docs"}, label})
+		assert.Error(t, err, label.Name)
+	}
+
+	assert.Nil(t, payload)
+
+	// the convenience wrapper skips empty names, e.g. of an empty Label
+	// header, and leaves validation to Confluence
+	_, err = api.AddPageLabels(page, []string{"docs", ""})
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{
+		{"prefix": "global", "name": "docs"},
+	}, payload)
+}
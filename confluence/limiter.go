@@ -0,0 +1,140 @@
+package confluence
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter throttles requests. It's satisfied by *rate.Limiter of
+// golang.org/x/time/rate.
+type Limiter interface {
+	// Wait blocks until a request may be sent or ctx is done.
+	Wait(ctx context.Context) error
+}
+
+// APIOptions configures an API created by NewAPIWithOptions.
+type APIOptions struct {
+	// Limiter gates every request sent to Confluence, including retries.
+	Limiter Limiter
+
+	// RequestsPerSecond and Burst configure a token bucket limiter that is
+	// used if Limiter is nil and RequestsPerSecond is positive. Burst
+	// defaults to 1.
+	RequestsPerSecond float64
+	Burst             int
+
+	// RetryAttempts overrides API.RetryAttempts if positive.
+	RetryAttempts int
+}
+
+// NewAPIWithOptions is like NewAPI, but applies the given options.
+func NewAPIWithOptions(
+	baseURL string,
+	username string,
+	password string,
+	options APIOptions,
+) *API {
+	return applyOptions(NewAPI(baseURL, username, password), options)
+}
+
+// NewAPIWithTokenOptions is like NewAPIWithToken, but applies the given
+// options.
+func NewAPIWithTokenOptions(
+	baseURL string,
+	token string,
+	options APIOptions,
+) *API {
+	return applyOptions(NewAPIWithToken(baseURL, token), options)
+}
+
+func applyOptions(api *API, options APIOptions) *API {
+	if options.RetryAttempts > 0 {
+		api.RetryAttempts = options.RetryAttempts
+	}
+
+	api.limiter = options.Limiter
+	if api.limiter == nil && options.RequestsPerSecond > 0 {
+		api.limiter = newTokenBucket(options.RequestsPerSecond, options.Burst)
+	}
+
+	return api
+}
+
+// tokenBucket is a Limiter allowing rps requests per second on average and
+// up to burst requests at once.
+//
+// It's a minimal stand-in for rate.Limiter of golang.org/x/time/rate, which
+// mark doesn't depend on: requests only ever need Wait, and pulling in a
+// module for these few lines isn't worth it. Users who want rate.Limiter's
+// full feature set can pass one as APIOptions.Limiter.
+type tokenBucket struct {
+	mutex  sync.Mutex
+	rps    float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rps float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &tokenBucket{
+		rps:    rps,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+func (bucket *tokenBucket) Wait(ctx context.Context) error {
+	delay := bucket.reserve(time.Now())
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		bucket.cancel()
+
+		return ctx.Err()
+	}
+}
+
+// reserve takes a token at the given time and returns how long the caller
+// has to wait until the token is actually available. Tokens are taken right
+// away, so concurrent callers queue up behind each other.
+func (bucket *tokenBucket) reserve(now time.Time) time.Duration {
+	bucket.mutex.Lock()
+	defer bucket.mutex.Unlock()
+
+	bucket.tokens = min(
+		bucket.burst,
+		bucket.tokens+now.Sub(bucket.last).Seconds()*bucket.rps,
+	)
+	bucket.last = now
+
+	bucket.tokens--
+
+	if bucket.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-bucket.tokens / bucket.rps * float64(time.Second))
+}
+
+// cancel returns a token taken by reserve whose caller gave up waiting, so
+// it doesn't delay the callers queued behind it.
+func (bucket *tokenBucket) cancel() {
+	bucket.mutex.Lock()
+	defer bucket.mutex.Unlock()
+
+	bucket.tokens = min(bucket.burst, bucket.tokens+1)
+}
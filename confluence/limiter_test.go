@@ -0,0 +1,141 @@
+package confluence
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAPIWithOptionsLimitsRequests(t *testing.T) {
+	var (
+		mutex sync.Mutex
+		times []time.Time
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			mutex.Lock()
+			times = append(times, time.Now())
+			mutex.Unlock()
+
+			_, _ = io.WriteString(w, `{"id": "1", "title": "Page"}`)
+		},
+	))
+	t.Cleanup(server.Close)
+
+	api := NewAPIWithOptions(server.URL, "user", "password", APIOptions{
+		RequestsPerSecond: 1,
+	})
+
+	for i := 0; i < 2; i++ {
+		_, err := api.GetPageByID("1")
+		assert.NoError(t, err)
+	}
+
+	assert.Len(t, times, 2)
+	assert.InDelta(t, time.Second, times[1].Sub(times[0]), float64(100*time.Millisecond))
+}
+
+func TestNewAPIWithOptionsRetryAttempts(t *testing.T) {
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(http.StatusTooManyRequests)
+		},
+	))
+	t.Cleanup(server.Close)
+
+	api := NewAPIWithOptions(server.URL, "user", "password", APIOptions{
+		RetryAttempts: 1,
+	})
+
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resource("content/1", &PageInfo{}).Get()
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	_, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	assert.Error(t, err)
+	assert.Equal(t, 1, requests)
+}
+
+func TestTokenBucketBurst(t *testing.T) {
+	bucket := newTokenBucket(10, 3)
+	now := bucket.last
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, time.Duration(0), bucket.reserve(now))
+	}
+
+	assert.Equal(t, 100*time.Millisecond, bucket.reserve(now))
+	assert.Equal(t, 200*time.Millisecond, bucket.reserve(now))
+}
+
+func TestTokenBucketRefill(t *testing.T) {
+	bucket := newTokenBucket(10, 3)
+	now := bucket.last
+
+	for i := 0; i < 3; i++ {
+		bucket.reserve(now)
+	}
+
+	// two tokens are refilled after 200ms
+	now = now.Add(200 * time.Millisecond)
+	assert.Equal(t, time.Duration(0), bucket.reserve(now))
+	assert.Equal(t, time.Duration(0), bucket.reserve(now))
+	assert.Equal(t, 100*time.Millisecond, bucket.reserve(now))
+
+	// refilling stops at burst
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, time.Duration(0), bucket.reserve(now))
+	}
+
+	assert.Equal(t, 100*time.Millisecond, bucket.reserve(now))
+}
+
+func TestTokenBucketWaitCancel(t *testing.T) {
+	bucket := newTokenBucket(1, 1)
+	assert.NoError(t, bucket.Wait(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorIs(t, bucket.Wait(ctx), context.Canceled)
+
+	// the cancelled waiter gave its token back
+	assert.InDelta(t, 0, bucket.tokens, 0.1)
+}
+
+func TestNewAPIWithTokenOptions(t *testing.T) {
+	var header string
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			header = r.Header.Get("Authorization")
+
+			_, _ = io.WriteString(w, `{"id": "1", "title": "Page"}`)
+		},
+	))
+	t.Cleanup(server.Close)
+
+	api := NewAPIWithTokenOptions(server.URL, "secret", APIOptions{
+		RetryAttempts: 2,
+	})
+
+	_, err := api.GetPageByID("1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer secret", header)
+	assert.Equal(t, 2, api.RetryAttempts)
+}
@@ -0,0 +1,118 @@
+package confluence
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/reconquest/karma-go"
+)
+
+var reMacroName = regexp.MustCompile(
+	`<ac:structured-macro[^>]*\sac:name="([^"]+)"`,
+)
+
+// ValidateMacrosAgainstInstance returns the names of the macros used in the
+// given storage-format body which aren't installed on the instance, which
+// usually means the plugin providing them is missing. The installed macros
+// are taken from the macro browser the editor uses for the given space.
+func (api *API) ValidateMacrosAgainstInstance(
+	space string,
+	storage string,
+) ([]string, error) {
+	installed, err := api.getAvailableMacros(space)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to retrieve macros available in space %q",
+			space,
+		)
+	}
+
+	unknown := []string{}
+	for _, match := range reMacroName.FindAllStringSubmatch(storage, -1) {
+		name := match[1]
+		if !installed[name] && !slices.Contains(unknown, name) {
+			unknown = append(unknown, name)
+		}
+	}
+
+	return unknown, nil
+}
+
+// getAvailableMacros returns the set of macro names the macro browser offers
+// in the given space.
+func (api *API) getAvailableMacros(space string) (map[string]bool, error) {
+	target := api.instanceURL("/plugins/macrobrowser/browse-macros.action")
+	target.RawQuery = "spaceKey=" + space
+
+	reqFn := func() (*http.Response, error) {
+		request, err := http.NewRequest(http.MethodGet, target.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		api.authorize(request)
+		request.Header.Set("Accept", "application/json")
+
+		return api.rest.Api.Client.Do(request)
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		time.Sleep(1 * time.Second)
+		return api.getAvailableMacros(space)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newErrorStatus(resp)
+	}
+
+	defer resp.Body.Close()
+
+	var result struct {
+		Macros []struct {
+			MacroName string `json:"macroName"`
+		} `json:"macros"`
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, karma.Format(err, "unable to decode macro browser response")
+	}
+
+	macros := map[string]bool{}
+	for _, macro := range result.Macros {
+		macros[macro.MacroName] = true
+	}
+
+	return macros, nil
+}
+
+// CountMacros returns how often each macro is used in the body of the given
+// page, which helps to notice generated pages with so many macros that
+// Confluence struggles to render them.
+func (api *API) CountMacros(pageID string) (map[string]int, error) {
+	storage, err := api.getPageStorage(pageID)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to retrieve body of page %q",
+			pageID,
+		)
+	}
+
+	counts := map[string]int{}
+	for _, match := range reMacroName.FindAllStringSubmatch(storage, -1) {
+		counts[match[1]]++
+	}
+
+	return counts, nil
+}
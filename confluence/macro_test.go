@@ -0,0 +1,47 @@
+package confluence
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateMacrosAgainstInstance(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/plugins/macrobrowser/browse-macros.action", r.URL.Path)
+		assert.Equal(t, "DOC", r.URL.Query().Get("spaceKey"))
+
+		_, _ = io.WriteString(w, `{
+			"macros": [{"macroName": "code"}, {"macroName": "info"}]
+		}`)
+	})
+
+	unknown, err := api.ValidateMacrosAgainstInstance(
+		"DOC",
+		`<ac:structured-macro ac:name="code"></ac:structured-macro>`+
+			`<ac:structured-macro ac:name="drawio" ac:schema-version="1">`+
+			`</ac:structured-macro>`+
+			`<ac:structured-macro ac:name="drawio"></ac:structured-macro>`,
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"drawio"}, unknown)
+}
+
+func TestCountMacros(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/content/42", r.URL.Path)
+		assert.Equal(t, "body.storage", r.URL.Query().Get("expand"))
+
+		_, _ = io.WriteString(w, `{"body": {"storage": {"value": `+
+			`"<ac:structured-macro ac:name=\"status\"></ac:structured-macro>`+
+			`<ac:structured-macro ac:name=\"code\"></ac:structured-macro>`+
+			`<p><ac:structured-macro ac:macro-id=\"1\" ac:name=\"status\">`+
+			`</ac:structured-macro></p>"}}}`)
+	})
+
+	counts, err := api.CountMacros("42")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"status": 2, "code": 1}, counts)
+}
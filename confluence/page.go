@@ -0,0 +1,676 @@
+package confluence
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kovetskiy/gopencils"
+	"github.com/reconquest/karma-go"
+	"github.com/reconquest/pkg/log"
+)
+
+// FindUnmanagedPages returns the pages of the given space that were not
+// published by mark, i.e. lack the PropertySource content property.
+func (api *API) FindUnmanagedPages(space string) ([]PageInfo, error) {
+	pages, err := api.listSourcedPages(space)
+	if err != nil {
+		return nil, karma.Format(err, "unable to list pages in space %q", space)
+	}
+
+	unmanaged := []PageInfo{}
+	for _, page := range pages {
+		if page.Source() == "" {
+			unmanaged = append(unmanaged, page.PageInfo)
+		}
+	}
+
+	return unmanaged, nil
+}
+
+// listSpacePages returns every page of the given space.
+func (api *API) listSpacePages(space string) ([]PageInfo, error) {
+	query := map[string]string{
+		"spaceKey": space,
+		"type":     "page",
+		"expand":   "ancestors,version",
+		"limit":    "100",
+	}
+
+	return fetchPaged[PageInfo](api, "content", query)
+}
+
+// sourcedPage is a page along with its PropertySource content property, as
+// returned when the property is expanded.
+type sourcedPage struct {
+	PageInfo
+
+	Metadata struct {
+		Properties struct {
+			Source struct {
+				Value SourceProperty `json:"value"`
+			} `json:"mark:source"`
+		} `json:"properties"`
+	} `json:"metadata"`
+}
+
+// Source returns the path of the markdown file the page was published from,
+// or an empty string if it wasn't published by mark.
+func (page sourcedPage) Source() string {
+	return page.Metadata.Properties.Source.Value.Path
+}
+
+// listSourcedPages returns every page of the given space like
+// listSpacePages, but expands the PropertySource content property so it
+// doesn't have to be requested for every page.
+func (api *API) listSourcedPages(space string) ([]sourcedPage, error) {
+	query := map[string]string{
+		"spaceKey": space,
+		"type":     "page",
+		"expand":   "ancestors,version,metadata.properties." + PropertySource,
+		"limit":    "100",
+	}
+
+	return fetchPaged[sourcedPage](api, "content", query)
+}
+
+// DeleteDraft deletes the draft version of the given page, leaving the
+// published version untouched. It's not an error if the page has no draft.
+func (api *API) DeleteDraft(pageID string) error {
+	var result interface{}
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resource(
+			"content/"+pageID, &result,
+		).Delete(map[string]string{"status": "draft"})
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		time.Sleep(1 * time.Second)
+		return api.DeleteDraft(pageID)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return newErrorStatus(resp)
+	}
+}
+
+// CreateFromBlueprint publishes the blueprint draft with the given ID as a
+// page in the given space. Entries of context are merged into the published
+// content, which allows setting its title, ancestors and similar fields.
+//
+// Confluence doesn't allow to instantiate a blueprint with a single REST
+// call: a draft has to be created from the blueprint first (the create dialog
+// on Server, the editor on Cloud) and blueprintID is the ID of that draft.
+// Server and legacy Cloud drafts are published with POST, while Cloud
+// instances using the collaborative editor only know shared drafts, which
+// are published with PUT.
+func (api *API) CreateFromBlueprint(
+	space string,
+	blueprintID string,
+	blueprintContext map[string]interface{},
+) (*PageInfo, error) {
+	payload := map[string]interface{}{
+		"type":   "page",
+		"status": "current",
+		"space": map[string]interface{}{
+			"key": space,
+		},
+		"version": map[string]interface{}{
+			"number": 1,
+		},
+	}
+
+	for key, value := range blueprintContext {
+		payload[key] = value
+	}
+
+	if title, ok := payload["title"].(string); ok {
+		err := validateTitle(title)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var page PageInfo
+	reqFn := func() (*http.Response, error) {
+		resource := api.resource(
+			"content/blueprint/instance/"+blueprintID, &page,
+		)
+
+		var (
+			request *gopencils.Resource
+			err     error
+		)
+
+		if api.isCloud() {
+			request, err = resource.Put(payload)
+		} else {
+			request, err = resource.Post(payload)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		time.Sleep(1 * time.Second)
+		return api.CreateFromBlueprint(space, blueprintID, blueprintContext)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newErrorStatus(resp)
+	}
+
+	return &page, nil
+}
+
+// getPageStorage returns the body of the given page in storage format.
+func (api *API) getPageStorage(pageID string) (string, error) {
+	var page struct {
+		Body struct {
+			Storage struct {
+				Value string `json:"value"`
+			} `json:"storage"`
+		} `json:"body"`
+	}
+
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resource(
+			"content/"+pageID, &page,
+		).Get(map[string]string{"expand": "body.storage"})
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		time.Sleep(1 * time.Second)
+		return api.getPageStorage(pageID)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", newErrorStatus(resp)
+	}
+
+	return page.Body.Storage.Value, nil
+}
+
+// HasComplexLayout reports whether the body of the given page is arranged in
+// multiple columns, either with page layouts or with the section and column
+// macros. Such layouts are usually crafted by hand in the editor and are lost
+// when the page is overwritten with content published by mark.
+func (api *API) HasComplexLayout(pageID string) (bool, error) {
+	storage, err := api.getPageStorage(pageID)
+	if err != nil {
+		return false, karma.Format(err, "unable to retrieve body of page %q", pageID)
+	}
+
+	return hasComplexLayout(storage), nil
+}
+
+var (
+	reLayoutSection = regexp.MustCompile(`<ac:layout-section[^>]*\sac:type="([^"]*)"`)
+	reColumnMacro   = regexp.MustCompile(`<ac:structured-macro[^>]*\sac:name="(section|column)"`)
+)
+
+func hasComplexLayout(storage string) bool {
+	for _, section := range reLayoutSection.FindAllStringSubmatch(storage, -1) {
+		if section[1] != "single" {
+			return true
+		}
+	}
+
+	return reColumnMacro.MatchString(storage)
+}
+
+// GetChildCount returns the number of direct children of the given page
+// without retrieving them. Instances that don't report the total number of
+// results have the children listed instead.
+func (api *API) GetChildCount(pageID string) (int, error) {
+	var result struct {
+		TotalSize *int `json:"totalSize"`
+	}
+
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resource(
+			"content/"+pageID+"/child/page", &result,
+		).Get(map[string]string{"limit": "0"})
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		time.Sleep(1 * time.Second)
+		return api.GetChildCount(pageID)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, newErrorStatus(resp)
+	}
+
+	if result.TotalSize != nil {
+		return *result.TotalSize, nil
+	}
+
+	children, err := api.GetChildPages(pageID)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(children), nil
+}
+
+// GetChildPages returns the direct children of the given page in the order
+// Confluence shows them.
+func (api *API) GetChildPages(pageID string) ([]PageInfo, error) {
+	query := map[string]string{
+		"expand": "version",
+		"limit":  "100",
+	}
+
+	return fetchPaged[PageInfo](api, "content/"+pageID+"/child/page", query)
+}
+
+// GetPageByIDVerifySpace is like GetPageByID but fails if the page doesn't
+// belong to the expected space. It guards against page IDs that point to a
+// page in another space, e.g. when copied between documents.
+func (api *API) GetPageByIDVerifySpace(
+	pageID string,
+	expectedSpace string,
+) (*PageInfo, error) {
+	var page PageInfo
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resource(
+			"content/"+pageID, &page,
+		).Get(map[string]string{"expand": "ancestors,version,space"})
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		time.Sleep(1 * time.Second)
+		return api.GetPageByIDVerifySpace(pageID, expectedSpace)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newErrorStatus(resp)
+	}
+
+	if page.Space.Key != expectedSpace {
+		return nil, karma.
+			Describe("page", pageID).
+			Describe("expected space", expectedSpace).
+			Describe("actual space", page.Space.Key).
+			Reason("page belongs to another space")
+	}
+
+	return &page, nil
+}
+
+// ListContent returns the content of the given space that has one of the
+// given types, e.g. "page", "blogpost" or "comment". All types are returned
+// if types is empty. At most limit items are returned unless limit is zero.
+func (api *API) ListContent(
+	space string,
+	types []string,
+	limit int,
+) ([]PageInfo, error) {
+	cql := fmt.Sprintf("space = %q", space)
+	if len(types) > 0 {
+		quoted := []string{}
+		for _, contentType := range types {
+			quoted = append(quoted, strconv.Quote(contentType))
+		}
+
+		cql += " and type in (" + strings.Join(quoted, ", ") + ")"
+	}
+
+	return api.SearchCQL(cql, limit)
+}
+
+// SearchCQL returns the content matching the given CQL query, e.g.
+// "label = docs and lastModified < now('-90d')", following the cursor of the
+// search results. At most limit items are returned unless limit is zero.
+func (api *API) SearchCQL(cql string, limit int) ([]PageInfo, error) {
+	batch := 100
+	if limit > 0 && limit < batch {
+		batch = limit
+	}
+
+	query := map[string]string{
+		"cql":    cql,
+		"expand": "ancestors,version",
+		"limit":  strconv.Itoa(batch),
+	}
+
+	contents := []PageInfo{}
+	for query != nil {
+		var result pagedList[PageInfo]
+
+		err := getPagedList(api, "content/search", query, &result)
+		if err != nil {
+			return nil, karma.Format(err, "unable to search content: %s", cql)
+		}
+
+		contents = append(contents, result.Results...)
+
+		if limit > 0 && len(contents) >= limit {
+			return contents[:limit], nil
+		}
+
+		query, err = nextPageQuery(result.Links.Next)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return contents, nil
+}
+
+// FindBlogPost returns the blog post of the given space with the given
+// title or nil if there is no such blog post. Unlike FindPage it doesn't need
+// the posting day, which Confluence requires to look up blog posts by title.
+func (api *API) FindBlogPost(space, title string) (*PageInfo, error) {
+	query := map[string]string{
+		"cql": fmt.Sprintf(
+			"space = %q and type = blogpost and title = %q",
+			space,
+			title,
+		),
+		"expand": "ancestors,version,space",
+		"limit":  "1",
+	}
+
+	var result pagedList[PageInfo]
+
+	err := getPagedList(api, "content/search", query, &result)
+	if err != nil {
+		return nil, karma.Format(err, "unable to find blog post %q", title)
+	}
+
+	if len(result.Results) == 0 {
+		return nil, nil
+	}
+
+	return &result.Results[0], nil
+}
+
+// UIDLabelPrefix precedes the UID in the label identifying a page, e.g.
+// "uid:abc-123".
+const UIDLabelPrefix = "uid:"
+
+// FindPageByUID returns the page of the given space carrying the label of
+// the given UID, which identifies the page regardless of its title and ID.
+// It returns nil if there is no such page and an error if several pages
+// carry the label.
+func (api *API) FindPageByUID(space, uid string) (*PageInfo, error) {
+	query := map[string]string{
+		"cql": fmt.Sprintf(
+			"space = %q and label = %q",
+			space,
+			UIDLabelPrefix+uid,
+		),
+		"expand": "ancestors,version,space",
+		"limit":  "2",
+	}
+
+	var result pagedList[PageInfo]
+
+	err := getPagedList(api, "content/search", query, &result)
+	if err != nil {
+		return nil, karma.Format(err, "unable to find page with UID %q", uid)
+	}
+
+	switch len(result.Results) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &result.Results[0], nil
+	default:
+		return nil, fmt.Errorf(
+			"duplicate UID %q: carried by pages %q and %q at least",
+			uid,
+			result.Results[0].ID,
+			result.Results[1].ID,
+		)
+	}
+}
+
+// ResolveRelativeLink resolves a link from the markdown file at
+// currentSourcePath to relativeTarget, e.g. "../other/file.md#setup", to
+// the page of the given space published from the target file and returns
+// a storage-format link to that page. Pages are matched by the path stored
+// in their PropertySource content property; it's an error if no page was
+// published from the target file.
+func (api *API) ResolveRelativeLink(
+	currentSourcePath string,
+	relativeTarget string,
+	space string,
+) (string, error) {
+	target, anchor, _ := strings.Cut(relativeTarget, "#")
+
+	source := path.Join(
+		path.Dir(filepath.ToSlash(currentSourcePath)),
+		filepath.ToSlash(target),
+	)
+
+	pages, err := api.listSourcedPages(space)
+	if err != nil {
+		return "", karma.Format(err, "unable to list pages in space %q", space)
+	}
+
+	for _, page := range pages {
+		if page.Source() == "" || path.Clean(page.Source()) != source {
+			continue
+		}
+
+		link := `<ac:link`
+		if anchor != "" {
+			link += ` ac:anchor="` + html.EscapeString(anchor) + `"`
+		}
+
+		return link + `><ri:page ri:content-title="` +
+			html.EscapeString(page.Title) +
+			`" ri:space-key="` + html.EscapeString(space) +
+			`" /></ac:link>`, nil
+	}
+
+	return "", fmt.Errorf(
+		"no page in space %q was published from %q",
+		space,
+		source,
+	)
+}
+
+// RepairAncestors moves the given page below its nearest ancestor that still
+// exists, or below the homepage of its space if none does. Nothing is changed
+// if the parent of the page exists or the page is at the top level of its
+// space.
+func (api *API) RepairAncestors(pageID string) error {
+	page, err := api.GetPageByID(pageID)
+	if err != nil {
+		return karma.Format(err, "unable to retrieve page %q", pageID)
+	}
+
+	if len(page.Ancestors) == 0 {
+		return nil
+	}
+
+	parentID := ""
+	for i := len(page.Ancestors) - 1; i >= 0; i-- {
+		ancestor := page.Ancestors[i]
+
+		exists, err := api.pageExists(ancestor.ID)
+		if err != nil {
+			return karma.Format(err, "unable to retrieve ancestor %q", ancestor.ID)
+		}
+
+		if exists {
+			parentID = ancestor.ID
+			break
+		}
+
+		log.Warningf(
+			nil,
+			"ancestor %q of page %q no longer exists",
+			ancestor.ID,
+			page.Title,
+		)
+	}
+
+	if parentID == page.Ancestors[len(page.Ancestors)-1].ID {
+		return nil
+	}
+
+	if parentID == "" {
+		homepage, err := api.EnsureHomepage(page.Space.Key)
+		if err != nil {
+			return karma.Format(
+				err,
+				"unable to retrieve homepage of space %q",
+				page.Space.Key,
+			)
+		}
+
+		if homepage.ID == page.ID {
+			return nil
+		}
+
+		parentID = homepage.ID
+	}
+
+	log.Infof(nil, "moving page %q below page %q", page.Title, parentID)
+
+	return api.movePage(context.Background(), page, parentID)
+}
+
+// pageExists reports whether there is content with the given ID.
+func (api *API) pageExists(pageID string) (bool, error) {
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resource(
+			"content/"+pageID, &map[string]interface{}{},
+		).Get()
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return false, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		time.Sleep(1 * time.Second)
+		return api.pageExists(pageID)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return false, newErrorStatus(resp)
+	}
+
+	return true, nil
+}
+
+// PageVersion is a single version in the history of a page.
+type PageVersion struct {
+	Number int64
+
+	// Author is the account ID on Cloud or the username on Server.
+	Author  string
+	When    time.Time
+	Message string
+}
+
+// GetPageVersions returns the history of the given page, newest first.
+func (api *API) GetPageVersions(pageID string) ([]PageVersion, error) {
+	results, err := fetchPaged[pageVersion](
+		api,
+		"content/"+pageID+"/version",
+		map[string]string{"limit": "200"},
+	)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to retrieve versions of page %q",
+			pageID,
+		)
+	}
+
+	versions := []PageVersion{}
+	for _, result := range results {
+		author := result.By.AccountID
+		if author == "" {
+			author = result.By.Username
+		}
+
+		versions = append(versions, PageVersion{
+			Number:  result.Number,
+			Author:  author,
+			When:    result.When,
+			Message: result.Message,
+		})
+	}
+
+	return versions, nil
+}
+
+type pageVersion struct {
+	Number  int64     `json:"number"`
+	When    time.Time `json:"when"`
+	Message string    `json:"message"`
+	By      struct {
+		AccountID string `json:"accountId"`
+		Username  string `json:"username"`
+	} `json:"by"`
+}
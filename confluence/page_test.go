@@ -0,0 +1,457 @@
+package confluence
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindUnmanagedPages(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/api/content":
+			assert.Equal(t, "DOC", r.URL.Query().Get("spaceKey"))
+			assert.Equal(
+				t,
+				"ancestors,version,metadata.properties.mark:source",
+				r.URL.Query().Get("expand"),
+			)
+			_, _ = io.WriteString(w, `{"results": [
+				{"id": "1", "title": "Managed", "metadata": {"properties": {
+					"mark:source": {"key": "mark:source", "value": {"path": "docs/index.md"}}
+				}}},
+				{"id": "2", "title": "Manual", "metadata": {"properties": {}}}
+			]}`)
+
+		default:
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+	})
+
+	pages, err := api.FindUnmanagedPages("DOC")
+	assert.NoError(t, err)
+	assert.Len(t, pages, 1)
+	assert.Equal(t, "Manual", pages[0].Title)
+}
+
+func TestDeleteDraft(t *testing.T) {
+	requests := 0
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		assert.Equal(t, http.MethodDelete, r.Method)
+		assert.Equal(t, "/rest/api/content/42", r.URL.Path)
+		assert.Equal(t, "status=draft", r.URL.RawQuery)
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	assert.NoError(t, api.DeleteDraft("42"))
+	assert.Equal(t, 1, requests)
+}
+
+func TestCreateFromBlueprint(t *testing.T) {
+	var payload map[string]interface{}
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/rest/api/content/blueprint/instance/draft-1", r.URL.Path)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+
+		_, _ = io.WriteString(w, `{"id": "42", "type": "page", "title": "Decision: use Go"}`)
+	})
+
+	page, err := api.CreateFromBlueprint("DOC", "draft-1", map[string]interface{}{
+		"title":     "Decision: use Go",
+		"ancestors": []map[string]interface{}{{"id": "7"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "42", page.ID)
+
+	assert.Equal(t, map[string]interface{}{
+		"type":      "page",
+		"status":    "current",
+		"title":     "Decision: use Go",
+		"space":     map[string]interface{}{"key": "DOC"},
+		"version":   map[string]interface{}{"number": 1.0},
+		"ancestors": []interface{}{map[string]interface{}{"id": "7"}},
+	}, payload)
+}
+
+func TestHasComplexLayout(t *testing.T) {
+	bodies := map[string]string{
+		"1": `<p>plain</p>`,
+		"2": `<ac:layout><ac:layout-section ac:type="single"><ac:layout-cell><p>a</p></ac:layout-cell></ac:layout-section></ac:layout>`,
+		"3": `<ac:layout><ac:layout-section ac:type="two_equal" ac:breakout-mode="default"><ac:layout-cell><p>a</p></ac:layout-cell><ac:layout-cell><p>b</p></ac:layout-cell></ac:layout-section></ac:layout>`,
+		"4": `<ac:structured-macro ac:name="section" ac:schema-version="1"><ac:rich-text-body><ac:structured-macro ac:name="column"></ac:structured-macro></ac:rich-text-body></ac:structured-macro>`,
+	}
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "body.storage", r.URL.Query().Get("expand"))
+
+		body := bodies[strings.TrimPrefix(r.URL.Path, "/rest/api/content/")]
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"body": map[string]interface{}{
+				"storage": map[string]interface{}{"value": body},
+			},
+		})
+	})
+
+	for id, expected := range map[string]bool{
+		"1": false,
+		"2": false,
+		"3": true,
+		"4": true,
+	} {
+		layout, err := api.HasComplexLayout(id)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, layout, "page %s", id)
+	}
+}
+
+func TestGetPageByIDVerifySpace(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/content/42", r.URL.Path)
+		assert.Equal(t, "ancestors,version,space", r.URL.Query().Get("expand"))
+
+		_, _ = io.WriteString(w, `{"id": "42", "title": "Page", "space": {"key": "OPS"}}`)
+	})
+
+	page, err := api.GetPageByIDVerifySpace("42", "OPS")
+	assert.NoError(t, err)
+	assert.Equal(t, "Page", page.Title)
+
+	page, err = api.GetPageByIDVerifySpace("42", "DOC")
+	assert.Nil(t, page)
+	assert.ErrorContains(t, err, "page belongs to another space")
+}
+
+func TestListContent(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/content/search", r.URL.Path)
+
+		if r.URL.Query().Get("start") == "" {
+			assert.Equal(
+				t,
+				`space = "DOC" and type in ("blogpost")`,
+				r.URL.Query().Get("cql"),
+			)
+
+			_, _ = io.WriteString(w, `{
+				"results": [{"id": "1", "type": "blogpost", "title": "Release 1.0"}],
+				"_links": {"next": "/rest/api/content/search?start=1"}
+			}`)
+			return
+		}
+
+		_, _ = io.WriteString(w, `{
+			"results": [
+				{"id": "2", "type": "blogpost", "title": "Release 1.1"},
+				{"id": "3", "type": "blogpost", "title": "Release 1.2"}
+			]
+		}`)
+	})
+
+	contents, err := api.ListContent("DOC", []string{"blogpost"}, 0)
+	assert.NoError(t, err)
+	assert.Len(t, contents, 3)
+	for _, content := range contents {
+		assert.Equal(t, "blogpost", content.Type)
+	}
+
+	contents, err = api.ListContent("DOC", []string{"blogpost"}, 2)
+	assert.NoError(t, err)
+	assert.Len(t, contents, 2)
+}
+
+func TestSearchCQL(t *testing.T) {
+	cql := "label = docs and lastModified < now('-90d')"
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/content/search", r.URL.Path)
+		assert.Equal(t, cql, r.URL.Query().Get("cql"))
+
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			assert.Equal(t, "ancestors,version", r.URL.Query().Get("expand"))
+
+			_, _ = io.WriteString(w, `{
+				"results": [{"id": "1", "type": "page", "title": "Old"}],
+				"_links": {
+					"next": "/rest/api/content/search?cql=label+%3D+docs+and+lastModified+%3C+now%28%27-90d%27%29&cursor=abc"
+				}
+			}`)
+		case "abc":
+			_, _ = io.WriteString(w, `{
+				"results": [{"id": "2", "type": "page", "title": "Older"}]
+			}`)
+		default:
+			t.Errorf("unexpected cursor: %q", r.URL.Query().Get("cursor"))
+		}
+	})
+
+	pages, err := api.SearchCQL(cql, 0)
+	assert.NoError(t, err)
+	assert.Len(t, pages, 2)
+	assert.Equal(t, "2", pages[1].ID)
+
+	empty := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"results": []}`)
+	})
+
+	pages, err = empty.SearchCQL(cql, 0)
+	assert.NoError(t, err)
+	assert.NotNil(t, pages)
+	assert.Empty(t, pages)
+}
+
+func TestRepairAncestors(t *testing.T) {
+	var payload map[string]interface{}
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rest/api/content/3" && r.Method == http.MethodGet:
+			_, _ = io.WriteString(w, `{
+				"id": "3",
+				"type": "page",
+				"title": "Child",
+				"version": {"number": 7},
+				"ancestors": [{"id": "1", "title": "Root"}, {"id": "2", "title": "Deleted"}],
+				"space": {"key": "DOC"}
+			}`)
+
+		case r.URL.Path == "/rest/api/content/3" && r.Method == http.MethodPut:
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			_, _ = io.WriteString(w, `{}`)
+
+		case r.URL.Path == "/rest/api/content/2":
+			w.WriteHeader(http.StatusNotFound)
+
+		case r.URL.Path == "/rest/api/content/1":
+			_, _ = io.WriteString(w, `{"id": "1", "title": "Root"}`)
+
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL)
+		}
+	})
+
+	assert.NoError(t, api.RepairAncestors("3"))
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"id": "1"},
+	}, payload["ancestors"])
+	assert.Equal(t, map[string]interface{}{"number": 8.0}, payload["version"])
+}
+
+func TestRepairAncestorsLeavesRootPage(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/content/3" || r.Method != http.MethodGet {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL)
+			return
+		}
+
+		_, _ = io.WriteString(w, `{
+			"id": "3",
+			"type": "page",
+			"title": "Top level",
+			"version": {"number": 7},
+			"ancestors": [],
+			"space": {"key": "DOC"}
+		}`)
+	})
+
+	assert.NoError(t, api.RepairAncestors("3"))
+}
+
+func TestGetPageVersions(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/content/1/version", r.URL.Path)
+
+		if r.URL.Query().Get("start") == "" {
+			_, _ = io.WriteString(w, `{
+				"results": [{
+					"number": 2,
+					"when": "2026-10-15T12:00:00.000Z",
+					"message": "Fix typo",
+					"by": {"accountId": "557058:jane"}
+				}],
+				"_links": {"next": "/rest/api/content/1/version?limit=1&start=1"}
+			}`)
+			return
+		}
+
+		_, _ = io.WriteString(w, `{
+			"results": [{
+				"number": 1,
+				"when": "2026-10-14T09:30:00.000Z",
+				"by": {"username": "john"}
+			}],
+			"_links": {}
+		}`)
+	})
+
+	versions, err := api.GetPageVersions("1")
+	assert.NoError(t, err)
+	assert.Equal(t, []PageVersion{
+		{
+			Number:  2,
+			Author:  "557058:jane",
+			When:    time.Date(2026, time.October, 15, 12, 0, 0, 0, time.UTC),
+			Message: "Fix typo",
+		},
+		{
+			Number: 1,
+			Author: "john",
+			When:   time.Date(2026, time.October, 14, 9, 30, 0, 0, time.UTC),
+		},
+	}, versions)
+}
+
+func TestGetChildCount(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/content/1/child/page", r.URL.Path)
+		assert.Equal(t, "0", r.URL.Query().Get("limit"))
+
+		_, _ = io.WriteString(w, `{"results": [], "size": 0, "totalSize": 5}`)
+	})
+
+	count, err := api.GetChildCount("1")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, count)
+}
+
+func TestFindPageByUID(t *testing.T) {
+	results := `[{"id": "1", "title": "Page"}]`
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/content/search", r.URL.Path)
+		assert.Equal(
+			t,
+			`space = "DOC" and label = "uid:abc-123"`,
+			r.URL.Query().Get("cql"),
+		)
+
+		_, _ = io.WriteString(w, `{"results": `+results+`}`)
+	})
+
+	page, err := api.FindPageByUID("DOC", "abc-123")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", page.ID)
+
+	results = `[{"id": "1", "title": "Page"}, {"id": "2", "title": "Copy"}]`
+
+	page, err = api.FindPageByUID("DOC", "abc-123")
+	assert.Nil(t, page)
+	assert.ErrorContains(t, err, `duplicate UID "abc-123"`)
+}
+
+func TestResolveRelativeLink(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/api/content":
+			_, _ = io.WriteString(w, `{"results": [
+				{"id": "1", "title": "Index", "metadata": {"properties": {
+					"mark:source": {"key": "mark:source", "value": {"path": "docs/guide/index.md"}}
+				}}},
+				{"id": "2", "title": "Setup & Install", "metadata": {"properties": {
+					"mark:source": {"key": "mark:source", "value": {"path": "docs/guide/setup.md"}}
+				}}}
+			]}`)
+
+		default:
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+	})
+
+	link, err := api.ResolveRelativeLink("docs/guide/index.md", "./setup.md#linux", "DOC")
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		`<ac:link ac:anchor="linux"><ri:page ri:content-title="Setup &amp; Install" ri:space-key="DOC" /></ac:link>`,
+		link,
+	)
+
+	_, err = api.ResolveRelativeLink("docs/guide/index.md", "../missing.md", "DOC")
+	assert.ErrorContains(t, err, `"docs/missing.md"`)
+}
+
+func TestGetChildPages(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/content/1/child/page", r.URL.Path)
+		assert.Equal(t, "version", r.URL.Query().Get("expand"))
+
+		if r.URL.Query().Get("start") == "" {
+			_, _ = io.WriteString(w, `{
+				"results": [{"id": "3", "title": "B"}, {"id": "2", "title": "A"}],
+				"_links": {"next": "/rest/api/content/1/child/page?expand=version&start=2"}
+			}`)
+			return
+		}
+
+		_, _ = io.WriteString(w, `{"results": [{"id": "4", "title": "C"}]}`)
+	})
+
+	children, err := api.GetChildPages("1")
+	assert.NoError(t, err)
+
+	ids := []string{}
+	for _, child := range children {
+		ids = append(ids, child.ID)
+	}
+
+	assert.Equal(t, []string{"3", "2", "4"}, ids)
+}
+
+func TestFindBlogPost(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/content/search", r.URL.Path)
+
+		switch r.URL.Query().Get("cql") {
+		case `space = "DOC" and type = blogpost and title = "Release 1.0"`:
+			_, _ = io.WriteString(w, `{
+				"results": [{"id": "7", "type": "blogpost", "title": "Release 1.0"}]
+			}`)
+		default:
+			_, _ = io.WriteString(w, `{"results": []}`)
+		}
+	})
+
+	post, err := api.FindBlogPost("DOC", "Release 1.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "7", post.ID)
+
+	post, err = api.FindBlogPost("DOC", "Release 2.0")
+	assert.NoError(t, err)
+	assert.Nil(t, post)
+}
+
+func TestCreateBlogPostWithoutAncestors(t *testing.T) {
+	var payload map[string]interface{}
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+
+		_, _ = io.WriteString(w, `{"id": "7", "type": "blogpost"}`)
+	})
+
+	_, err := api.CreatePageWithDate(
+		"DOC",
+		"blogpost",
+		&PageInfo{ID: "1"},
+		"Release 1.0",
+		"",
+		time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+	)
+	assert.NoError(t, err)
+	assert.NotContains(t, payload, "ancestors")
+	assert.Equal(
+		t,
+		map[string]interface{}{"createdDate": "2024-05-01T00:00:00Z"},
+		payload["history"],
+	)
+}
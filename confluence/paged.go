@@ -0,0 +1,74 @@
+package confluence
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// pagedList is a single page of a paginated response.
+type pagedList[T any] struct {
+	Results []T `json:"results"`
+	Size    int `json:"size"`
+	Links   struct {
+		Next string `json:"next"`
+	} `json:"_links"`
+}
+
+// fetchPaged requests path with the given query and follows the _links.next
+// field of the responses until all results are read.
+func fetchPaged[T any](
+	api *API,
+	path string,
+	query map[string]string,
+) ([]T, error) {
+	results := []T{}
+	for query != nil {
+		var list pagedList[T]
+
+		err := getPagedList(api, path, query, &list)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, list.Results...)
+
+		query, err = nextPageQuery(list.Links.Next)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+func getPagedList[T any](
+	api *API,
+	path string,
+	query map[string]string,
+	result *pagedList[T],
+) error {
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resource(path, result).Get(query)
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		time.Sleep(1 * time.Second)
+		return getPagedList(api, path, query, result)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return newErrorStatus(resp)
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package confluence
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchPaged(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/content", r.URL.Path)
+		assert.Equal(t, "DOC", r.URL.Query().Get("spaceKey"))
+
+		if r.URL.Query().Get("start") == "" {
+			_, _ = io.WriteString(w, `{
+				"results": [{"id": "1", "title": "One"}, {"id": "2", "title": "Two"}],
+				"size": 2,
+				"_links": {"next": "/rest/api/content?spaceKey=DOC&limit=2&start=2"}
+			}`)
+			return
+		}
+
+		assert.Equal(t, "2", r.URL.Query().Get("start"))
+		_, _ = io.WriteString(w, `{
+			"results": [{"id": "3", "title": "Three"}],
+			"size": 1,
+			"_links": {}
+		}`)
+	})
+
+	pages, err := fetchPaged[PageInfo](api, "content", map[string]string{
+		"spaceKey": "DOC",
+		"limit":    "2",
+	})
+	assert.NoError(t, err)
+
+	titles := []string{}
+	for _, page := range pages {
+		titles = append(titles, page.Title)
+	}
+
+	assert.Equal(t, []string{"One", "Two", "Three"}, titles)
+}
@@ -0,0 +1,404 @@
+package confluence
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kovetskiy/gopencils"
+	"github.com/reconquest/karma-go"
+)
+
+const (
+	// PropertyReviewDate stores the date a page is due for review.
+	PropertyReviewDate = "mark:review-by"
+
+	// PropertySource stores the path of the markdown file a page was
+	// published from.
+	PropertySource = "mark:source"
+
+	// PropertyOwner stores the account ID of the person responsible for a
+	// page.
+	PropertyOwner = "mark:owner"
+
+	// PropertyBodyHash stores the hash of the page body as it was right after
+	// mark published it.
+	PropertyBodyHash = "mark:body-hash"
+
+	// PropertyMarkVersion stores the version of mark that last published a
+	// page.
+	PropertyMarkVersion = "mark:version"
+)
+
+// markProperties are the content properties mark maintains on pages. New
+// properties have to be added here so SnapshotPageMeta captures them.
+var markProperties = []string{
+	PropertySource,
+	PropertyReviewDate,
+	PropertyOwner,
+	PropertyLabels,
+	PropertyBodyHash,
+	PropertyMarkVersion,
+}
+
+// SourceProperty is the value of the PropertySource content property.
+type SourceProperty struct {
+	Path string `json:"path"`
+}
+
+// OwnerProperty is the value of the PropertyOwner content property.
+type OwnerProperty struct {
+	AccountID string `json:"accountId"`
+}
+
+// MarkVersionProperty is the value of the PropertyMarkVersion content
+// property.
+type MarkVersionProperty struct {
+	Version string `json:"version"`
+}
+
+// BodyHashProperty is the value of the PropertyBodyHash content property.
+type BodyHashProperty struct {
+	Hash string `json:"hash"`
+}
+
+type contentProperty struct {
+	Key     string          `json:"key"`
+	Value   json.RawMessage `json:"value"`
+	Version struct {
+		Number int64 `json:"number"`
+	} `json:"version"`
+}
+
+// GetContentProperty reads the content property with the given key of the
+// given page and unmarshals its value into value. It reports false if the
+// property is not set on the page.
+func (api *API) GetContentProperty(
+	pageID string,
+	key string,
+	value interface{},
+) (bool, error) {
+	property, err := api.getContentProperty(pageID, key)
+	if err != nil {
+		return false, err
+	}
+
+	if property == nil {
+		return false, nil
+	}
+
+	err = json.Unmarshal(property.Value, value)
+	if err != nil {
+		return false, karma.Format(
+			err,
+			"unable to unmarshal content property %q: %s",
+			key,
+			string(property.Value),
+		)
+	}
+
+	return true, nil
+}
+
+// SetContentProperty creates the content property with the given key on the
+// given page or updates it if it already exists.
+func (api *API) SetContentProperty(
+	pageID string,
+	key string,
+	value interface{},
+) error {
+	property, err := api.getContentProperty(pageID, key)
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]interface{}{
+		"key":   key,
+		"value": value,
+	}
+
+	if property != nil {
+		payload["version"] = map[string]interface{}{
+			"number": property.Version.Number + 1,
+		}
+	}
+
+	var result contentProperty
+	reqFn := func() (*http.Response, error) {
+		var (
+			request *gopencils.Resource
+			err     error
+		)
+
+		if property == nil {
+			request, err = api.resource(
+				"content/"+pageID+"/property", &result,
+			).Post(payload)
+		} else {
+			request, err = api.resource(
+				"content/"+pageID+"/property/"+key, &result,
+			).Put(payload)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		time.Sleep(1 * time.Second)
+		return api.SetContentProperty(pageID, key, value)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return newErrorStatus(resp)
+	}
+
+	return nil
+}
+
+func (api *API) getContentProperty(
+	pageID string,
+	key string,
+) (*contentProperty, error) {
+	var property contentProperty
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resource(
+			"content/"+pageID+"/property/"+key, &property,
+		).Get()
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		time.Sleep(1 * time.Second)
+		return api.getContentProperty(pageID, key)
+	}
+
+	// 404 means that property is not set yet
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newErrorStatus(resp)
+	}
+
+	return &property, nil
+}
+
+// SetReviewDate stores the date the page is due for review as an ISO-8601
+// timestamp in the PropertyReviewDate content property.
+func (api *API) SetReviewDate(pageID string, due time.Time) error {
+	return api.SetContentProperty(
+		pageID,
+		PropertyReviewDate,
+		map[string]string{
+			"due": due.UTC().Format(time.RFC3339),
+		},
+	)
+}
+
+// GetReviewDate returns the date the page is due for review. The zero time is
+// returned if no review date is set.
+func (api *API) GetReviewDate(pageID string) (time.Time, error) {
+	var value struct {
+		Due string `json:"due"`
+	}
+
+	found, err := api.GetContentProperty(pageID, PropertyReviewDate, &value)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !found {
+		return time.Time{}, nil
+	}
+
+	due, err := time.Parse(time.RFC3339, value.Due)
+	if err != nil {
+		return time.Time{}, karma.Format(
+			err,
+			"unable to parse review date: %q",
+			value.Due,
+		)
+	}
+
+	return due, nil
+}
+
+// SetPageOwner stores the account ID of the person responsible for the page
+// in the PropertyOwner content property. The account must exist. On
+// Confluence Server and Data Center the username is stored instead.
+func (api *API) SetPageOwner(pageID, accountID string) error {
+	_, err := api.GetUserByAccountID(accountID)
+	if err != nil {
+		return karma.Format(err, "unable to validate page owner %q", accountID)
+	}
+
+	return api.SetContentProperty(
+		pageID,
+		PropertyOwner,
+		OwnerProperty{AccountID: accountID},
+	)
+}
+
+// GetPageOwner returns the account ID of the person responsible for the page
+// or an empty string if no owner is set.
+func (api *API) GetPageOwner(pageID string) (string, error) {
+	var owner OwnerProperty
+
+	_, err := api.GetContentProperty(pageID, PropertyOwner, &owner)
+	if err != nil {
+		return "", err
+	}
+
+	return owner.AccountID, nil
+}
+
+// SetMarkVersion records the version of mark that published the given page.
+func (api *API) SetMarkVersion(pageID, version string) error {
+	return api.SetContentProperty(
+		pageID,
+		PropertyMarkVersion,
+		MarkVersionProperty{Version: version},
+	)
+}
+
+// GetMarkVersion returns the version of mark that last published the given
+// page or an empty string if the page was published by a version of mark that
+// didn't record it.
+func (api *API) GetMarkVersion(pageID string) (string, error) {
+	var version MarkVersionProperty
+
+	_, err := api.GetContentProperty(pageID, PropertyMarkVersion, &version)
+	if err != nil {
+		return "", err
+	}
+
+	return version.Version, nil
+}
+
+// RecordBodyHash stores the hash of the current body of the given page in
+// the PropertyBodyHash content property. It's meant to be called right after
+// publishing, so WasEditedExternally can detect later edits. The body is read
+// back from Confluence rather than taken from what was sent, as Confluence
+// rewrites bodies when storing them, e.g. by adding ri:version-at-save.
+func (api *API) RecordBodyHash(pageID string) error {
+	storage, err := api.getPageStorage(pageID)
+	if err != nil {
+		return karma.Format(err, "unable to retrieve body of page %q", pageID)
+	}
+
+	return api.SetContentProperty(
+		pageID,
+		PropertyBodyHash,
+		BodyHashProperty{Hash: bodyHash(storage)},
+	)
+}
+
+// WasEditedExternally reports whether the body of the given page changed
+// since mark published it, i.e. whether its hash differs from expectedHash.
+// If expectedHash is empty, the hash recorded by RecordBodyHash is used
+// instead; pages without a recorded hash are reported as unchanged.
+func (api *API) WasEditedExternally(pageID, expectedHash string) (bool, error) {
+	if expectedHash == "" {
+		var recorded BodyHashProperty
+
+		_, err := api.GetContentProperty(pageID, PropertyBodyHash, &recorded)
+		if err != nil {
+			return false, err
+		}
+
+		if recorded.Hash == "" {
+			return false, nil
+		}
+
+		expectedHash = recorded.Hash
+	}
+
+	storage, err := api.getPageStorage(pageID)
+	if err != nil {
+		return false, karma.Format(err, "unable to retrieve body of page %q", pageID)
+	}
+
+	return bodyHash(storage) != expectedHash, nil
+}
+
+func bodyHash(storage string) string {
+	sum := sha256.Sum256([]byte(storage))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// emojiConcurrency is the number of pages SetEmojiForPages updates at the
+// same time.
+const emojiConcurrency = 4
+
+// SetEmojiForPages sets the title emoji of the given pages without creating
+// a new version of their bodies. Pages are updated concurrently; the returned
+// map holds the error of every page that couldn't be updated, keyed by page
+// ID. An error is returned only if emoji isn't usable at all.
+func (api *API) SetEmojiForPages(
+	pageIDs []string,
+	emoji string,
+) (map[string]error, error) {
+	if emoji == "" {
+		return nil, errors.New("emoji is empty")
+	}
+
+	value := emojiHex(emoji)
+
+	var (
+		mutex sync.Mutex
+		errs  = map[string]error{}
+	)
+
+	parallel(len(pageIDs), emojiConcurrency, func(i int) {
+		err := api.setPageEmoji(pageIDs[i], value)
+		if err != nil {
+			mutex.Lock()
+			errs[pageIDs[i]] = err
+			mutex.Unlock()
+		}
+	})
+
+	return errs, nil
+}
+
+func (api *API) setPageEmoji(pageID string, value string) error {
+	for _, key := range []string{"emoji-title-published", "emoji-title-draft"} {
+		err := api.SetContentProperty(pageID, key, value)
+		if err != nil {
+			return karma.Format(err, "unable to set content property %q", key)
+		}
+	}
+
+	return nil
+}
+
+// emojiHex returns the hex code point of the first rune of emoji, which is how
+// Confluence stores title emojis.
+func emojiHex(emoji string) string {
+	r, _ := utf8.DecodeRuneInString(emoji)
+	return fmt.Sprintf("%x", r)
+}
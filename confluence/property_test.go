@@ -0,0 +1,228 @@
+package confluence
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// propertyStore emulates the content property endpoints of a single page.
+type propertyStore struct {
+	mu         sync.Mutex
+	properties map[string]contentProperty
+}
+
+func newPropertyStore() *propertyStore {
+	return &propertyStore{properties: map[string]contentProperty{}}
+}
+
+func (s *propertyStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, key, _ := strings.Cut(r.URL.Path, "/property")
+	key = strings.TrimPrefix(key, "/")
+
+	switch r.Method {
+	case http.MethodGet:
+		property, ok := s.properties[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(property)
+
+	case http.MethodPost, http.MethodPut:
+		var property contentProperty
+		if err := json.NewDecoder(r.Body).Decode(&property); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if property.Version.Number == 0 {
+			property.Version.Number = 1
+		}
+
+		s.properties[property.Key] = property
+
+		_ = json.NewEncoder(w).Encode(property)
+	}
+}
+
+func TestReviewDateRoundTrip(t *testing.T) {
+	store := newPropertyStore()
+	api := newTestAPI(t, store.ServeHTTP)
+
+	due, err := api.GetReviewDate("1")
+	assert.NoError(t, err)
+	assert.True(t, due.IsZero())
+
+	expected := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, api.SetReviewDate("1", expected))
+
+	due, err = api.GetReviewDate("1")
+	assert.NoError(t, err)
+	assert.True(t, expected.Equal(due))
+
+	expected = expected.AddDate(0, 6, 0)
+	assert.NoError(t, api.SetReviewDate("1", expected))
+	assert.EqualValues(t, 2, store.properties[PropertyReviewDate].Version.Number)
+
+	due, err = api.GetReviewDate("1")
+	assert.NoError(t, err)
+	assert.True(t, expected.Equal(due))
+}
+
+func TestPageOwner(t *testing.T) {
+	store := newPropertyStore()
+	api := newCloudTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/wiki/rest/api/user" {
+			store.ServeHTTP(w, r)
+			return
+		}
+
+		if r.URL.Query().Get("accountId") != "557058:alice" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"accountId": "557058:alice"}`))
+	})
+
+	owner, err := api.GetPageOwner("1")
+	assert.NoError(t, err)
+	assert.Empty(t, owner)
+
+	assert.Error(t, api.SetPageOwner("1", "557058:nobody"))
+	assert.NotContains(t, store.properties, PropertyOwner)
+
+	assert.NoError(t, api.SetPageOwner("1", "557058:alice"))
+	assert.JSONEq(
+		t,
+		`{"accountId": "557058:alice"}`,
+		string(store.properties[PropertyOwner].Value),
+	)
+
+	owner, err = api.GetPageOwner("1")
+	assert.NoError(t, err)
+	assert.Equal(t, "557058:alice", owner)
+}
+
+func TestPageOwnerServer(t *testing.T) {
+	store := newPropertyStore()
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/user" {
+			store.ServeHTTP(w, r)
+			return
+		}
+
+		assert.Empty(t, r.URL.Query().Get("accountId"))
+
+		if r.URL.Query().Get("username") != "alice" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"userKey": "ff8080817c2a4b5e"}`))
+	})
+
+	err := api.SetPageOwner("1", "bob")
+	assert.ErrorContains(t, err, "user is not found")
+
+	assert.NoError(t, api.SetPageOwner("1", "alice"))
+
+	owner, err := api.GetPageOwner("1")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", owner)
+}
+
+func TestWasEditedExternally(t *testing.T) {
+	store := newPropertyStore()
+	body := "<p>published</p>"
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/content/1" {
+			store.ServeHTTP(w, r)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"body": map[string]interface{}{
+				"storage": map[string]interface{}{"value": body},
+			},
+		})
+	})
+
+	edited, err := api.WasEditedExternally("1", "")
+	assert.NoError(t, err)
+	assert.False(t, edited)
+
+	assert.NoError(t, api.RecordBodyHash("1"))
+
+	edited, err = api.WasEditedExternally("1", "")
+	assert.NoError(t, err)
+	assert.False(t, edited)
+
+	body = "<p>published</p><p>added in the editor</p>"
+
+	edited, err = api.WasEditedExternally("1", "")
+	assert.NoError(t, err)
+	assert.True(t, edited)
+
+	edited, err = api.WasEditedExternally("1", bodyHash(body))
+	assert.NoError(t, err)
+	assert.False(t, edited)
+}
+
+func TestSetEmojiForPages(t *testing.T) {
+	stores := map[string]*propertyStore{
+		"1": newPropertyStore(),
+		"2": newPropertyStore(),
+	}
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		// only content properties are written, the pages stay untouched
+		assert.Contains(t, r.URL.Path, "/property")
+
+		pageID, _, _ := strings.Cut(
+			strings.TrimPrefix(r.URL.Path, "/rest/api/content/"),
+			"/",
+		)
+
+		stores[pageID].ServeHTTP(w, r)
+	})
+
+	errs, err := api.SetEmojiForPages([]string{"1", "2"}, "📘")
+	assert.NoError(t, err)
+	assert.Empty(t, errs)
+
+	for _, pageID := range []string{"1", "2"} {
+		for _, key := range []string{"emoji-title-published", "emoji-title-draft"} {
+			var value string
+			ok, err := api.GetContentProperty(pageID, key, &value)
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, "1f4d8", value)
+		}
+	}
+}
+
+func TestGetMarkVersion(t *testing.T) {
+	store := newPropertyStore()
+	store.properties[PropertyMarkVersion] = contentProperty{
+		Key:   PropertyMarkVersion,
+		Value: json.RawMessage(`{"version": "15.0.0@abc123"}`),
+	}
+
+	api := newTestAPI(t, store.ServeHTTP)
+
+	version, err := api.GetMarkVersion("1")
+	assert.NoError(t, err)
+	assert.Equal(t, "15.0.0@abc123", version)
+}
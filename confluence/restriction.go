@@ -0,0 +1,346 @@
+package confluence
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/reconquest/karma-go"
+)
+
+// RestrictReadToGroups restricts viewing the given page to members of the
+// given groups. On Cloud the group names are resolved to group IDs first.
+// The deployment, if given, overrides the one of the API for this call.
+func (api *API) RestrictReadToGroups(
+	pageID string,
+	groups []string,
+	deployment ...Deployment,
+) error {
+	if api.isCloudFor(deployment) {
+		return api.restrictReadCloud(pageID, nil, groups)
+	}
+
+	return api.setContentPermissions(
+		context.Background(),
+		pageID,
+		"View",
+		nil,
+		groups,
+	)
+}
+
+// restrictReadCloud restricts viewing the given page to the given users and
+// members of the given groups, which are resolved like
+// RestrictPageUpdatesCloud does.
+func (api *API) restrictReadCloud(
+	pageID string,
+	users []string,
+	groups []string,
+) error {
+	restrictions, err := api.cloudRestrictions(users, groups)
+	if err != nil {
+		return err
+	}
+
+	var result interface{}
+
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resource(
+			"content/"+pageID+"/restriction", &result,
+		).Post([]map[string]interface{}{
+			{
+				"operation":    "read",
+				"restrictions": restrictions,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		time.Sleep(1 * time.Second)
+		return api.restrictReadCloud(pageID, users, groups)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return newErrorStatus(resp)
+	}
+
+	return nil
+}
+
+// getGroupID returns the ID of the group with the given name. Group IDs are
+// only available on Cloud.
+func (api *API) getGroupID(name string) (string, error) {
+	var group struct {
+		ID string `json:"id"`
+	}
+
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resource(
+			"group/by-name", &group,
+		).Get(map[string]string{"name": name})
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		time.Sleep(1 * time.Second)
+		return api.getGroupID(name)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", newErrorStatus(resp)
+	}
+
+	return group.ID, nil
+}
+
+// CopyRestrictions replaces the restrictions of the destination page with
+// those of the source page.
+func (api *API) CopyRestrictions(srcID, destID string) error {
+	restrictions, err := api.GetPageRestrictions(srcID)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to retrieve restrictions of page %q",
+			srcID,
+		)
+	}
+
+	err = api.replaceRestrictions(destID, restrictions)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to set restrictions of page %q",
+			destID,
+		)
+	}
+
+	return nil
+}
+
+// replaceRestrictions makes the read and update restrictions of the given
+// page exactly the given ones. On Server setting a permission replaces it;
+// on Cloud restrictions can only be added, so all are removed first.
+func (api *API) replaceRestrictions(
+	pageID string,
+	restrictions *Restrictions,
+) error {
+	if !api.isCloud() {
+		err := api.setContentPermissions(
+			context.Background(),
+			pageID,
+			"View",
+			restrictions.Read.Users,
+			restrictions.Read.Groups,
+		)
+		if err != nil {
+			return karma.Format(err, "unable to restrict reading")
+		}
+
+		err = api.RestrictPageUpdatesServer(
+			&PageInfo{ID: pageID},
+			restrictions.Update.Users,
+			restrictions.Update.Groups,
+		)
+		if err != nil {
+			return karma.Format(err, "unable to restrict updates")
+		}
+
+		return nil
+	}
+
+	err := api.deleteRestrictions(pageID)
+	if err != nil {
+		return karma.Format(err, "unable to remove restrictions")
+	}
+
+	read := restrictions.Read
+	if len(read.Users) > 0 || len(read.Groups) > 0 {
+		err = api.restrictReadCloud(pageID, read.Users, read.Groups)
+		if err != nil {
+			return karma.Format(err, "unable to restrict reading")
+		}
+	}
+
+	update := restrictions.Update
+	if len(update.Users) > 0 || len(update.Groups) > 0 {
+		err = api.RestrictPageUpdatesCloud(
+			&PageInfo{ID: pageID},
+			update.Users,
+			update.Groups,
+		)
+		if err != nil {
+			return karma.Format(err, "unable to restrict updates")
+		}
+	}
+
+	return nil
+}
+
+// deleteRestrictions removes all restrictions of the given page on Cloud.
+func (api *API) deleteRestrictions(pageID string) error {
+	var result interface{}
+
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resource(
+			"content/"+pageID+"/restriction", &result,
+		).Delete()
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		time.Sleep(1 * time.Second)
+		return api.deleteRestrictions(pageID)
+	}
+
+	if resp.StatusCode != http.StatusOK &&
+		resp.StatusCode != http.StatusNoContent {
+		return newErrorStatus(resp)
+	}
+
+	return nil
+}
+
+// RestrictPageUpdatesTo restricts editing the given page to the given users
+// and members of the given groups, see RestrictPageUpdatesCloud and
+// RestrictPageUpdatesServer. The deployment, if given, overrides the one of
+// the API for this call.
+func (api *API) RestrictPageUpdatesTo(
+	page *PageInfo,
+	users []string,
+	groups []string,
+	deployment ...Deployment,
+) error {
+	if api.isCloudFor(deployment) {
+		return api.RestrictPageUpdatesCloud(page, users, groups)
+	}
+
+	return api.RestrictPageUpdatesServer(page, users, groups)
+}
+
+// PageRestriction lists the users and groups an operation on a page, e.g.
+// "read" or "update", is restricted to.
+type PageRestriction struct {
+	Operation string
+	Users     []string
+	Groups    []string
+}
+
+// Restrictions are the users and groups allowed to read and to update a page.
+// Empty slices mean the operation isn't restricted.
+type Restrictions struct {
+	Read   PageRestriction
+	Update PageRestriction
+}
+
+// GetPageRestrictions returns the read and update restrictions of the given
+// page.
+func (api *API) GetPageRestrictions(pageID string) (*Restrictions, error) {
+	var result struct {
+		Read   operationRestriction `json:"read"`
+		Update operationRestriction `json:"update"`
+	}
+
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resource(
+			"content/"+pageID+"/restriction/byOperation", &result,
+		).Get(map[string]string{
+			"expand": "read.restrictions.user,read.restrictions.group," +
+				"update.restrictions.user,update.restrictions.group",
+		})
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		time.Sleep(1 * time.Second)
+		return api.GetPageRestrictions(pageID)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newErrorStatus(resp)
+	}
+
+	restrictions := &Restrictions{
+		Read:   result.Read.pageRestriction(),
+		Update: result.Update.pageRestriction(),
+	}
+
+	restrictions.Read.Operation = "read"
+	restrictions.Update.Operation = "update"
+
+	return restrictions, nil
+}
+
+// operationRestriction is the restriction of a single operation as returned
+// by the REST API.
+type operationRestriction struct {
+	Operation    string `json:"operation"`
+	Restrictions struct {
+		User struct {
+			Results []struct {
+				AccountID string `json:"accountId"`
+				Username  string `json:"username"`
+			} `json:"results"`
+		} `json:"user"`
+		Group struct {
+			Results []struct {
+				Name string `json:"name"`
+			} `json:"results"`
+		} `json:"group"`
+	} `json:"restrictions"`
+}
+
+func (operation operationRestriction) pageRestriction() PageRestriction {
+	restriction := PageRestriction{
+		Operation: operation.Operation,
+		Users:     []string{},
+		Groups:    []string{},
+	}
+
+	for _, user := range operation.Restrictions.User.Results {
+		// Cloud identifies users by account ID, Server by username
+		if user.AccountID != "" {
+			restriction.Users = append(restriction.Users, user.AccountID)
+		} else {
+			restriction.Users = append(restriction.Users, user.Username)
+		}
+	}
+
+	for _, group := range operation.Restrictions.Group.Results {
+		restriction.Groups = append(restriction.Groups, group.Name)
+	}
+
+	return restriction
+}
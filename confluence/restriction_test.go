@@ -0,0 +1,344 @@
+package confluence
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestrictReadToGroupsCloud(t *testing.T) {
+	var payload []map[string]interface{}
+
+	api := newCloudTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/wiki/rest/api/group/by-name":
+			_, _ = io.WriteString(
+				w,
+				`{"type": "group", "id": "id-`+r.URL.Query().Get("name")+`"}`,
+			)
+
+		case "/wiki/rest/api/content/42/restriction":
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			_, _ = io.WriteString(w, `{}`)
+
+		default:
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+	})
+
+	assert.NoError(t, api.RestrictReadToGroups("42", []string{"hr", "legal"}))
+
+	assert.Equal(t, []map[string]interface{}{
+		{
+			"operation": "read",
+			"restrictions": map[string]interface{}{
+				"group": []interface{}{
+					map[string]interface{}{"type": "group", "name": "hr", "id": "id-hr"},
+					map[string]interface{}{"type": "group", "name": "legal", "id": "id-legal"},
+				},
+			},
+		},
+	}, payload)
+}
+
+func TestRestrictReadToGroupsServer(t *testing.T) {
+	var params []interface{}
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rpc/json-rpc/confluenceservice-v2/setContentPermissions", r.URL.Path)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&params))
+		_, _ = io.WriteString(w, `true`)
+	})
+
+	assert.NoError(t, api.RestrictReadToGroups("42", []string{"hr"}))
+
+	assert.Equal(t, []interface{}{
+		"42",
+		"View",
+		[]interface{}{map[string]interface{}{"groupName": "hr"}},
+	}, params)
+}
+
+func TestCopyRestrictionsCloud(t *testing.T) {
+	var requests []string
+	payloads := map[string][]map[string]interface{}{}
+
+	api := newCloudTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+
+		switch {
+		case r.URL.Path == "/wiki/rest/api/content/1/restriction/byOperation":
+			_, _ = io.WriteString(w, `{
+				"read": {"operation": "read", "restrictions": {
+					"user": {"results": []},
+					"group": {"results": []}
+				}},
+				"update": {"operation": "update", "restrictions": {
+					"user": {"results": [{"accountId": "5b10a2844c20165700ede21a"}]},
+					"group": {"results": [{"name": "writers"}]}
+				}}
+			}`)
+
+		case r.URL.Path == "/wiki/rest/api/group/by-name":
+			_, _ = io.WriteString(w, `{"id": "id-`+r.URL.Query().Get("name")+`"}`)
+
+		case r.URL.Path == "/wiki/rest/api/content/2/restriction" &&
+			r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+
+		case r.URL.Path == "/wiki/rest/api/content/2/restriction" &&
+			r.Method == http.MethodPost:
+			var payload []map[string]interface{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			payloads[payload[0]["operation"].(string)] = payload
+			_, _ = io.WriteString(w, `{}`)
+
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL)
+		}
+	})
+
+	assert.NoError(t, api.CopyRestrictions("1", "2"))
+
+	assert.Equal(t, []string{
+		"GET /wiki/rest/api/content/1/restriction/byOperation",
+		"DELETE /wiki/rest/api/content/2/restriction",
+		"GET /wiki/rest/api/group/by-name",
+		"POST /wiki/rest/api/content/2/restriction",
+	}, requests)
+
+	assert.Equal(t, map[string][]map[string]interface{}{
+		"update": {{
+			"operation": "update",
+			"restrictions": map[string]interface{}{
+				"user": []interface{}{
+					map[string]interface{}{
+						"type":      "known",
+						"accountId": "5b10a2844c20165700ede21a",
+					},
+				},
+				"group": []interface{}{
+					map[string]interface{}{
+						"type": "group",
+						"name": "writers",
+						"id":   "id-writers",
+					},
+				},
+			},
+		}},
+	}, payloads)
+}
+
+func TestCopyRestrictionsServer(t *testing.T) {
+	var calls [][]interface{}
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/rest/api/content/1/restriction/byOperation" {
+			_, _ = io.WriteString(w, `{
+				"read": {"operation": "read", "restrictions": {
+					"user": {"results": []},
+					"group": {"results": [{"name": "staff"}]}
+				}},
+				"update": {"operation": "update", "restrictions": {
+					"user": {"results": [{"username": "alice"}]},
+					"group": {"results": []}
+				}}
+			}`)
+			return
+		}
+
+		assert.Equal(t, "/rpc/json-rpc/confluenceservice-v2/setContentPermissions", r.URL.Path)
+
+		var params []interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&params))
+		calls = append(calls, params)
+
+		_, _ = io.WriteString(w, `true`)
+	})
+
+	assert.NoError(t, api.CopyRestrictions("1", "2"))
+
+	assert.Equal(t, [][]interface{}{
+		{"2", "View", []interface{}{map[string]interface{}{"groupName": "staff"}}},
+		{"2", "Edit", []interface{}{map[string]interface{}{"userName": "alice"}}},
+	}, calls)
+}
+
+func TestRestrictPageUpdatesToCloud(t *testing.T) {
+	var payload []map[string]interface{}
+
+	api := newCloudTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/wiki/rest/api/group/by-name":
+			_, _ = io.WriteString(w, `{"id": "id-`+r.URL.Query().Get("name")+`"}`)
+
+		case "/wiki/rest/api/search/user":
+			assert.Equal(t, `user.fullname~"Bob"`, r.URL.Query().Get("cql"))
+			_, _ = io.WriteString(w, `{"results": [{"user": {"accountId": "acc-bob"}}]}`)
+
+		case "/wiki/rest/api/content/42/restriction":
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			_, _ = io.WriteString(w, `{}`)
+
+		default:
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+	})
+
+	err := api.RestrictPageUpdatesTo(
+		&PageInfo{ID: "42"},
+		[]string{"5b10a2844c20165700ede21a", "Bob"},
+		[]string{"team"},
+	)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []map[string]interface{}{
+		{
+			"operation": "update",
+			"restrictions": map[string]interface{}{
+				"user": []interface{}{
+					map[string]interface{}{
+						"type":      "known",
+						"accountId": "5b10a2844c20165700ede21a",
+					},
+					map[string]interface{}{"type": "known", "accountId": "acc-bob"},
+				},
+				"group": []interface{}{
+					map[string]interface{}{"type": "group", "name": "team", "id": "id-team"},
+				},
+			},
+		},
+	}, payload)
+}
+
+func TestRestrictPageUpdatesToServer(t *testing.T) {
+	var params []interface{}
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rpc/json-rpc/confluenceservice-v2/setContentPermissions", r.URL.Path)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&params))
+		_, _ = io.WriteString(w, `true`)
+	})
+
+	err := api.RestrictPageUpdatesTo(&PageInfo{ID: "42"}, []string{"alice"}, []string{"team"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []interface{}{
+		"42",
+		"Edit",
+		[]interface{}{
+			map[string]interface{}{"userName": "alice"},
+			map[string]interface{}{"groupName": "team"},
+		},
+	}, params)
+}
+
+func TestRestrictReadToGroupsDeploymentOverride(t *testing.T) {
+	var paths []string
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+
+		if r.URL.Path == "/rest/api/group/by-name" {
+			_, _ = io.WriteString(w, `{"id": "id-hr"}`)
+			return
+		}
+
+		_, _ = io.WriteString(w, `{}`)
+	})
+
+	assert.NoError(t, api.RestrictReadToGroups("42", []string{"hr"}, DeploymentCloud))
+
+	assert.Equal(t, []string{
+		"/rest/api/group/by-name",
+		"/rest/api/content/42/restriction",
+	}, paths)
+
+	api.Deployment = DeploymentCloud
+	paths = nil
+
+	// json-rpc answers with true, so the {} of the fake server is an error
+	err := api.RestrictReadToGroups("42", []string{"hr"}, DeploymentServer)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"/rpc/json-rpc/confluenceservice-v2/setContentPermissions"}, paths)
+}
+
+func TestRestrictPageUpdatesCloudUsers(t *testing.T) {
+	var payload []map[string]interface{}
+
+	api := newCloudTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/wiki/rest/api/user/current":
+			_, _ = io.WriteString(w, `{"accountId": "acc-self"}`)
+
+		case "/wiki/rest/api/search/user":
+			assert.Equal(t, `user.fullname~"Bob"`, r.URL.Query().Get("cql"))
+			_, _ = io.WriteString(w, `{"results": [{"user": {"accountId": "acc-bob"}}]}`)
+
+		case "/wiki/rest/api/content/42/restriction":
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			_, _ = io.WriteString(w, `{}`)
+
+		default:
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+	})
+
+	err := api.RestrictPageUpdatesCloud(&PageInfo{ID: "42"}, []string{"user", "Bob"}, nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []map[string]interface{}{
+		{
+			"operation": "update",
+			"restrictions": map[string]interface{}{
+				"user": []interface{}{
+					map[string]interface{}{"type": "known", "accountId": "acc-self"},
+					map[string]interface{}{"type": "known", "accountId": "acc-bob"},
+				},
+			},
+		},
+	}, payload)
+}
+
+func TestGetPageRestrictions(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/content/42/restriction/byOperation", r.URL.Path)
+
+		_, _ = io.WriteString(w, `{
+			"read": {
+				"operation": "read",
+				"restrictions": {
+					"user": {"results": []},
+					"group": {"results": [{"name": "staff"}]}
+				}
+			},
+			"update": {
+				"operation": "update",
+				"restrictions": {
+					"user": {"results": [{"username": "alice"}]},
+					"group": {"results": [{"name": "writers"}]}
+				}
+			}
+		}`)
+	})
+
+	restrictions, err := api.GetPageRestrictions("42")
+	assert.NoError(t, err)
+
+	assert.Equal(t, &Restrictions{
+		Read: PageRestriction{
+			Operation: "read",
+			Users:     []string{},
+			Groups:    []string{"staff"},
+		},
+		Update: PageRestriction{
+			Operation: "update",
+			Users:     []string{"alice"},
+			Groups:    []string{"writers"},
+		},
+	}, restrictions)
+}
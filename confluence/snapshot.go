@@ -0,0 +1,107 @@
+package confluence
+
+import (
+	"encoding/json"
+
+	"github.com/reconquest/karma-go"
+)
+
+// MetaSnapshot is the metadata of a page captured by SnapshotPageMeta.
+type MetaSnapshot struct {
+	Labels       []string
+	Restrictions Restrictions
+
+	// Properties maps the keys of mark's content properties that are set on
+	// the page to their values.
+	Properties map[string]json.RawMessage
+}
+
+// SnapshotPageMeta captures the global labels, the restrictions and mark's
+// content properties of the given page, so they can be put back with
+// RestorePageMeta.
+func (api *API) SnapshotPageMeta(pageID string) (MetaSnapshot, error) {
+	snapshot := MetaSnapshot{
+		Labels:     []string{},
+		Properties: map[string]json.RawMessage{},
+	}
+
+	labels, err := api.GetPageLabels(&PageInfo{ID: pageID}, "global")
+	if err != nil {
+		return MetaSnapshot{}, karma.Format(err, "unable to retrieve labels")
+	}
+
+	for _, label := range labels.Labels {
+		snapshot.Labels = append(snapshot.Labels, label.Name)
+	}
+
+	restrictions, err := api.GetPageRestrictions(pageID)
+	if err != nil {
+		return MetaSnapshot{}, karma.Format(err, "unable to retrieve restrictions")
+	}
+
+	snapshot.Restrictions = *restrictions
+
+	for _, key := range markProperties {
+		property, err := api.getContentProperty(pageID, key)
+		if err != nil {
+			return MetaSnapshot{}, karma.Format(
+				err,
+				"unable to retrieve content property %q",
+				key,
+			)
+		}
+
+		if property != nil {
+			snapshot.Properties[key] = property.Value
+		}
+	}
+
+	return snapshot, nil
+}
+
+// RestorePageMeta puts the metadata captured by SnapshotPageMeta back on the
+// given page. Labels and restrictions are replaced as a whole; the content
+// properties of the snapshot are written, while properties that were not set
+// at the time of the snapshot are left as they are.
+func (api *API) RestorePageMeta(pageID string, snapshot MetaSnapshot) error {
+	page := &PageInfo{ID: pageID}
+
+	labels, err := api.GetPageLabels(page, "global")
+	if err != nil {
+		return karma.Format(err, "unable to retrieve labels")
+	}
+
+	current := []string{}
+	for _, label := range labels.Labels {
+		current = append(current, label.Name)
+	}
+
+	missing := subtractLabels(snapshot.Labels, current)
+	if len(missing) > 0 {
+		_, err = api.AddPageLabels(page, missing)
+		if err != nil {
+			return karma.Format(err, "unable to add labels")
+		}
+	}
+
+	for _, label := range subtractLabels(current, snapshot.Labels) {
+		_, err = api.DeletePageLabel(page, label)
+		if err != nil {
+			return karma.Format(err, "unable to delete label %q", label)
+		}
+	}
+
+	err = api.replaceRestrictions(pageID, &snapshot.Restrictions)
+	if err != nil {
+		return karma.Format(err, "unable to restore restrictions")
+	}
+
+	for key, value := range snapshot.Properties {
+		err = api.SetContentProperty(pageID, key, value)
+		if err != nil {
+			return karma.Format(err, "unable to restore content property %q", key)
+		}
+	}
+
+	return nil
+}
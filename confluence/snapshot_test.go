@@ -0,0 +1,92 @@
+package confluence
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotRestorePageMeta(t *testing.T) {
+	labels := &labelStore{
+		labels:     []string{"docs", "api"},
+		properties: newPropertyStore(),
+	}
+
+	restrictions := `{
+		"read": {"operation": "read", "restrictions": {
+			"user": {"results": [{"username": "alice"}]},
+			"group": {"results": [{"name": "hr"}]}
+		}},
+		"update": {"operation": "update", "restrictions": {
+			"user": {"results": []},
+			"group": {"results": [{"name": "writers"}]}
+		}}
+	}`
+
+	var restored [][]interface{}
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rest/api/content/1/restriction/byOperation":
+			_, _ = io.WriteString(w, restrictions)
+
+		case strings.HasSuffix(r.URL.Path, "/setContentPermissions"):
+			var params []interface{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&params))
+			restored = append(restored, params)
+			_, _ = io.WriteString(w, `true`)
+
+		default:
+			labels.ServeHTTP(w, r)
+		}
+	})
+
+	assert.NoError(t, api.SetContentProperty("1", PropertySource, SourceProperty{Path: "a.md"}))
+	assert.NoError(t, api.SetContentProperty("1", PropertyBodyHash, BodyHashProperty{Hash: "abc"}))
+	assert.NoError(t, api.SetMarkVersion("1", "14.0.2"))
+
+	snapshot, err := api.SnapshotPageMeta("1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"docs", "api"}, snapshot.Labels)
+	assert.Equal(t, Restrictions{
+		Read: PageRestriction{
+			Operation: "read",
+			Users:     []string{"alice"},
+			Groups:    []string{"hr"},
+		},
+		Update: PageRestriction{
+			Operation: "update",
+			Users:     []string{},
+			Groups:    []string{"writers"},
+		},
+	}, snapshot.Restrictions)
+	assert.JSONEq(t, `{"path": "a.md"}`, string(snapshot.Properties[PropertySource]))
+	assert.Contains(t, snapshot.Properties, PropertyBodyHash)
+	assert.Contains(t, snapshot.Properties, PropertyMarkVersion)
+
+	// a risky migration goes wrong
+	labels.labels = []string{"docs", "migrated"}
+	assert.NoError(t, api.SetContentProperty("1", PropertySource, SourceProperty{Path: "b.md"}))
+
+	assert.NoError(t, api.RestorePageMeta("1", snapshot))
+
+	assert.ElementsMatch(t, []string{"docs", "api"}, labels.labels)
+	assert.JSONEq(
+		t,
+		`{"path": "a.md"}`,
+		string(labels.properties.properties[PropertySource].Value),
+	)
+	assert.Equal(t, [][]interface{}{
+		{"1", "View", []interface{}{
+			map[string]interface{}{"userName": "alice"},
+			map[string]interface{}{"groupName": "hr"},
+		}},
+		{"1", "Edit", []interface{}{
+			map[string]interface{}{"groupName": "writers"},
+		}},
+	}, restored)
+}
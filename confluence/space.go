@@ -0,0 +1,490 @@
+package confluence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/reconquest/karma-go"
+	"github.com/reconquest/pkg/log"
+)
+
+// SpacePermission describes a single permission granted in a space to either
+// a user or a group.
+//
+// Operation holds the permission name as reported by the instance: Server
+// uses json-rpc names like "VIEWSPACE" or "EDITSPACE", while Cloud reports
+// "<operation>:<target>" pairs like "read:space" or "create:page".
+type SpacePermission struct {
+	Operation string
+	UserName  string
+	AccountID string
+	GroupName string
+}
+
+// GetSpacePermissions returns the permission matrix of the given space.
+// Server instances are queried over json-rpc, Cloud instances over the REST
+// space API.
+func (api *API) GetSpacePermissions(space string) ([]SpacePermission, error) {
+	if api.isCloud() {
+		return api.getSpacePermissionsCloud(space)
+	}
+
+	return api.getSpacePermissionsServer(space)
+}
+
+func (api *API) getSpacePermissionsCloud(space string) ([]SpacePermission, error) {
+	var result struct {
+		Permissions []struct {
+			Operation struct {
+				Operation  string `json:"operation"`
+				TargetType string `json:"targetType"`
+			} `json:"operation"`
+			Subjects struct {
+				User struct {
+					Results []struct {
+						AccountID string `json:"accountId"`
+						Username  string `json:"username"`
+					} `json:"results"`
+				} `json:"user"`
+				Group struct {
+					Results []struct {
+						Name string `json:"name"`
+					} `json:"results"`
+				} `json:"group"`
+			} `json:"subjects"`
+		} `json:"permissions"`
+	}
+
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resource(
+			"space/"+space, &result,
+		).Get(map[string]string{"expand": "permissions"})
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		time.Sleep(1 * time.Second)
+		return api.getSpacePermissionsCloud(space)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newErrorStatus(resp)
+	}
+
+	permissions := []SpacePermission{}
+	for _, permission := range result.Permissions {
+		operation := permission.Operation.Operation + ":" +
+			permission.Operation.TargetType
+
+		for _, user := range permission.Subjects.User.Results {
+			permissions = append(permissions, SpacePermission{
+				Operation: operation,
+				UserName:  user.Username,
+				AccountID: user.AccountID,
+			})
+		}
+
+		for _, group := range permission.Subjects.Group.Results {
+			permissions = append(permissions, SpacePermission{
+				Operation: operation,
+				GroupName: group.Name,
+			})
+		}
+	}
+
+	return permissions, nil
+}
+
+func (api *API) getSpacePermissionsServer(space string) ([]SpacePermission, error) {
+	var result []struct {
+		Type             string `json:"type"`
+		SpacePermissions []struct {
+			Type      string `json:"type"`
+			UserName  string `json:"userName"`
+			GroupName string `json:"groupName"`
+		} `json:"spacePermissions"`
+	}
+
+	reqFn := func() (*http.Response, error) {
+		request, err := api.rpc(
+			"getSpacePermissionSets", &result,
+		).Post([]interface{}{space})
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		time.Sleep(1 * time.Second)
+		return api.getSpacePermissionsServer(space)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newErrorStatus(resp)
+	}
+
+	permissions := []SpacePermission{}
+	for _, set := range result {
+		for _, permission := range set.SpacePermissions {
+			operation := permission.Type
+			if operation == "" {
+				operation = set.Type
+			}
+
+			permissions = append(permissions, SpacePermission{
+				Operation: operation,
+				UserName:  permission.UserName,
+				GroupName: permission.GroupName,
+			})
+		}
+	}
+
+	return permissions, nil
+}
+
+// EnsureSpace returns the space with the given key, creating it with the
+// given name first if it doesn't exist yet.
+func (api *API) EnsureSpace(key, name string) (*SpaceInfo, error) {
+	space, err := api.getSpace(key)
+	if err != nil {
+		return nil, karma.Format(err, "unable to retrieve space %q", key)
+	}
+
+	if space != nil {
+		return space, nil
+	}
+
+	log.Infof(nil, "creating space %q: %s", key, name)
+
+	space, err = api.CreateSpace(key, name)
+	if err != nil {
+		return nil, karma.Format(err, "unable to create space %q", key)
+	}
+
+	return space, nil
+}
+
+// CreateSpace creates a new global space with the given key and name.
+func (api *API) CreateSpace(key, name string) (*SpaceInfo, error) {
+	payload := map[string]interface{}{
+		"key":  key,
+		"name": name,
+	}
+
+	var space SpaceInfo
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resource("space", &space).Post(payload)
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		time.Sleep(1 * time.Second)
+		return api.CreateSpace(key, name)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newErrorStatus(resp)
+	}
+
+	return &space, nil
+}
+
+// SpaceNotFoundError is returned by GetSpace if there is no space with the
+// given key or the user isn't allowed to see it.
+type SpaceNotFoundError struct {
+	Key string
+}
+
+func (err *SpaceNotFoundError) Error() string {
+	return fmt.Sprintf("space %q not found", err.Key)
+}
+
+// GetSpace returns the space with the given key including its homepage. A
+// *SpaceNotFoundError is returned if there is no such space.
+func (api *API) GetSpace(key string) (*SpaceInfo, error) {
+	return api.GetSpaceContext(context.Background(), key)
+}
+
+// GetSpaceContext is like GetSpace but aborts once ctx is cancelled.
+func (api *API) GetSpaceContext(ctx context.Context, key string) (*SpaceInfo, error) {
+	var space SpaceInfo
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resourceContext(
+			ctx,
+			"space/"+key, &space,
+		).Get(map[string]string{"expand": "homepage"})
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(ctx, api.RetryAttempts, reqFn)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		err = sleepContext(ctx, 1*time.Second)
+		if err != nil {
+			return nil, err
+		}
+
+		return api.GetSpaceContext(ctx, key)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &SpaceNotFoundError{Key: key}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newErrorStatus(resp)
+	}
+
+	return &space, nil
+}
+
+// getSpace returns the space with the given key or nil if there is no such
+// space.
+func (api *API) getSpace(key string) (*SpaceInfo, error) {
+	space, err := api.GetSpace(key)
+
+	var notFound *SpaceNotFoundError
+	if errors.As(err, &notFound) {
+		return nil, nil
+	}
+
+	return space, err
+}
+
+// GetSpacePageCount returns the number of pages in the given space, which
+// allows to warn before a batch publish runs into a page quota configured for
+// the space. Only the total size of the search is requested, no results.
+func (api *API) GetSpacePageCount(space string) (int, error) {
+	var result struct {
+		TotalSize int `json:"totalSize"`
+	}
+
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resource(
+			"content/search", &result,
+		).Get(map[string]string{
+			"cql":   fmt.Sprintf("space = %q and type = page", space),
+			"limit": "0",
+		})
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		time.Sleep(1 * time.Second)
+		return api.GetSpacePageCount(space)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, newErrorStatus(resp)
+	}
+
+	return result.TotalSize, nil
+}
+
+// Theme holds the look and feel colors of a space.
+type Theme struct {
+	HeadingColor          string
+	LinkColor             string
+	HeaderBackgroundColor string
+	BorderColor           string
+}
+
+// DefaultTheme is the look and feel of Confluence without customizations. It
+// is returned by GetSpaceTheme where the look and feel can't be read.
+var DefaultTheme = Theme{
+	HeadingColor:          "#172B4D",
+	LinkColor:             "#0052CC",
+	HeaderBackgroundColor: "#FFFFFF",
+	BorderColor:           "#DFE1E6",
+}
+
+type lookAndFeel struct {
+	Headings struct {
+		Color string `json:"color"`
+	} `json:"headings"`
+	Links struct {
+		Color string `json:"color"`
+	} `json:"links"`
+	Header struct {
+		BackgroundColor string `json:"backgroundColor"`
+	} `json:"header"`
+	BordersAndDividers struct {
+		Color string `json:"color"`
+	} `json:"bordersAndDividers"`
+}
+
+// GetSpaceTheme returns the colors of the look and feel selected for the
+// given space. DefaultTheme is returned if the instance doesn't expose the
+// look and feel settings, which is the case for Confluence Server. Colors the
+// instance doesn't report are taken from DefaultTheme as well.
+func (api *API) GetSpaceTheme(spaceKey string) (Theme, error) {
+	var result struct {
+		Selected string       `json:"selected"`
+		Global   *lookAndFeel `json:"global"`
+		Custom   *lookAndFeel `json:"custom"`
+	}
+
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resource(
+			"settings/lookandfeel", &result,
+		).Get(map[string]string{"spaceKey": spaceKey})
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return Theme{}, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		time.Sleep(1 * time.Second)
+		return api.GetSpaceTheme(spaceKey)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return DefaultTheme, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return Theme{}, newErrorStatus(resp)
+	}
+
+	selected := result.Global
+	if result.Selected == "custom" && result.Custom != nil {
+		selected = result.Custom
+	}
+
+	theme := DefaultTheme
+	if selected == nil {
+		return theme, nil
+	}
+
+	if selected.Headings.Color != "" {
+		theme.HeadingColor = selected.Headings.Color
+	}
+
+	if selected.Links.Color != "" {
+		theme.LinkColor = selected.Links.Color
+	}
+
+	if selected.Header.BackgroundColor != "" {
+		theme.HeaderBackgroundColor = selected.Header.BackgroundColor
+	}
+
+	if selected.BordersAndDividers.Color != "" {
+		theme.BorderColor = selected.BordersAndDividers.Color
+	}
+
+	return theme, nil
+}
+
+// EnsureHomepage returns the homepage of the given space. If the space has
+// no homepage, which is the case for spaces created without content, a page
+// named "<space name> Home" is created and made the homepage.
+func (api *API) EnsureHomepage(space string) (*PageInfo, error) {
+	info, err := api.getSpace(space)
+	if err != nil {
+		return nil, karma.Format(err, "unable to retrieve space %q", space)
+	}
+
+	if info == nil {
+		return nil, karma.Describe("space", space).Reason("no such space")
+	}
+
+	if info.Homepage.ID != "" {
+		return &info.Homepage, nil
+	}
+
+	title := info.Name + " Home"
+
+	log.Infof(nil, "creating homepage %q of space %q", title, space)
+
+	page, err := api.CreatePage(space, "page", nil, title, "")
+	if err != nil {
+		return nil, karma.Format(err, "unable to create homepage %q", title)
+	}
+
+	err = api.setSpaceHomepage(info, page.ID)
+	if err != nil {
+		return nil, karma.Format(err, "unable to set homepage of space %q", space)
+	}
+
+	return page, nil
+}
+
+func (api *API) setSpaceHomepage(space *SpaceInfo, pageID string) error {
+	payload := map[string]interface{}{
+		"name": space.Name,
+		"homepage": map[string]interface{}{
+			"id": pageID,
+		},
+	}
+
+	var result interface{}
+
+	reqFn := func() (*http.Response, error) {
+		request, err := api.resource("space/"+space.Key, &result).Put(payload)
+		if err != nil {
+			return nil, err
+		}
+		return request.Raw, nil
+	}
+
+	resp, err := api.doWithRetry(context.Background(), api.RetryAttempts, reqFn)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		time.Sleep(1 * time.Second)
+		return api.setSpaceHomepage(space, pageID)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return newErrorStatus(resp)
+	}
+
+	return nil
+}
@@ -0,0 +1,201 @@
+package confluence
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSpacePermissionsServer(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rpc/json-rpc/confluenceservice-v2/getSpacePermissionSets", r.URL.Path)
+
+		var params []string
+		body, _ := io.ReadAll(r.Body)
+		assert.NoError(t, json.Unmarshal(body, &params))
+		assert.Equal(t, []string{"DOC"}, params)
+
+		_, _ = io.WriteString(w, `[
+			{"type": "VIEWSPACE", "spacePermissions": [
+				{"type": "VIEWSPACE", "groupName": "confluence-users"},
+				{"type": "VIEWSPACE", "userName": "alice"}
+			]},
+			{"type": "EDITSPACE", "spacePermissions": [
+				{"type": "EDITSPACE", "userName": "bob"}
+			]}
+		]`)
+	})
+
+	permissions, err := api.GetSpacePermissions("DOC")
+	assert.NoError(t, err)
+	assert.Equal(t, []SpacePermission{
+		{Operation: "VIEWSPACE", GroupName: "confluence-users"},
+		{Operation: "VIEWSPACE", UserName: "alice"},
+		{Operation: "EDITSPACE", UserName: "bob"},
+	}, permissions)
+}
+
+func TestEnsureSpace(t *testing.T) {
+	spaces := map[string]SpaceInfo{
+		"OLD": {ID: 1, Key: "OLD", Name: "Existing"},
+	}
+	created := 0
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			space, ok := spaces[strings.TrimPrefix(r.URL.Path, "/rest/api/space/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			_ = json.NewEncoder(w).Encode(space)
+
+		case http.MethodPost:
+			assert.Equal(t, "/rest/api/space", r.URL.Path)
+
+			var space SpaceInfo
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&space))
+
+			space.ID = 2
+			spaces[space.Key] = space
+			created++
+
+			_ = json.NewEncoder(w).Encode(space)
+		}
+	})
+
+	space, err := api.EnsureSpace("OLD", "Ignored")
+	assert.NoError(t, err)
+	assert.Equal(t, "Existing", space.Name)
+	assert.Equal(t, 0, created)
+
+	space, err = api.EnsureSpace("NEW", "Fresh")
+	assert.NoError(t, err)
+	assert.Equal(t, &SpaceInfo{ID: 2, Key: "NEW", Name: "Fresh"}, space)
+	assert.Equal(t, 1, created)
+
+	_, err = api.EnsureSpace("NEW", "Fresh")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, created)
+}
+
+func TestGetSpace(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "homepage", r.URL.Query().Get("expand"))
+
+		if r.URL.Path != "/rest/api/space/DOC" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = io.WriteString(w, `{
+			"id": 98306,
+			"key": "DOC",
+			"name": "Documentation",
+			"homepage": {"id": "65540", "title": "Documentation Home"}
+		}`)
+	})
+
+	space, err := api.GetSpace("DOC")
+	assert.NoError(t, err)
+	assert.Equal(t, 98306, space.ID)
+	assert.Equal(t, "Documentation", space.Name)
+	assert.Equal(t, "65540", space.Homepage.ID)
+
+	_, err = api.GetSpace("doc")
+
+	var notFound *SpaceNotFoundError
+	assert.ErrorAs(t, err, &notFound)
+	assert.Equal(t, "doc", notFound.Key)
+
+	_, err = api.FindHomePage("doc")
+	assert.ErrorAs(t, err, &notFound)
+}
+
+func TestGetSpacePageCount(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/content/search", r.URL.Path)
+		assert.Equal(t, `space = "DOC" and type = page`, r.URL.Query().Get("cql"))
+		assert.Equal(t, "0", r.URL.Query().Get("limit"))
+
+		_, _ = io.WriteString(w, `{"results": [], "size": 0, "totalSize": 4711}`)
+	})
+
+	count, err := api.GetSpacePageCount("DOC")
+	assert.NoError(t, err)
+	assert.Equal(t, 4711, count)
+}
+
+func TestGetSpaceTheme(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/settings/lookandfeel", r.URL.Path)
+
+		if r.URL.Query().Get("spaceKey") != "DOC" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = io.WriteString(w, `{
+			"selected": "custom",
+			"global": {"headings": {"color": "#000000"}},
+			"custom": {
+				"headings": {"color": "#333333"},
+				"links": {"color": "#FF5630"},
+				"header": {"backgroundColor": "#0747A6"}
+			}
+		}`)
+	})
+
+	theme, err := api.GetSpaceTheme("DOC")
+	assert.NoError(t, err)
+	assert.Equal(t, Theme{
+		HeadingColor:          "#333333",
+		LinkColor:             "#FF5630",
+		HeaderBackgroundColor: "#0747A6",
+		BorderColor:           DefaultTheme.BorderColor,
+	}, theme)
+
+	theme, err = api.GetSpaceTheme("OTHER")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultTheme, theme)
+}
+
+func TestEnsureHomepage(t *testing.T) {
+	var homepage map[string]interface{}
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/rest/api/space/NEW":
+			_, _ = io.WriteString(w, `{"id": 1, "key": "NEW", "name": "Fresh"}`)
+
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/content/":
+			var payload map[string]interface{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			assert.Equal(t, "Fresh Home", payload["title"])
+			assert.NotContains(t, payload, "ancestors")
+
+			_, _ = io.WriteString(w, `{"id": "42", "title": "Fresh Home"}`)
+
+		case r.Method == http.MethodPut && r.URL.Path == "/rest/api/space/NEW":
+			var payload map[string]interface{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			homepage, _ = payload["homepage"].(map[string]interface{})
+
+			_, _ = io.WriteString(w, `{}`)
+
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL)
+		}
+	})
+
+	page, err := api.EnsureHomepage("NEW")
+	assert.NoError(t, err)
+	assert.Equal(t, "42", page.ID)
+	assert.Equal(t, map[string]interface{}{"id": "42"}, homepage)
+}
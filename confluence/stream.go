@@ -0,0 +1,113 @@
+package confluence
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httputil"
+)
+
+// streamThreshold is the size of a page body in bytes from which CreatePage
+// and UpdatePage stream the request instead of marshalling it in memory
+// first.
+const streamThreshold = 1 << 20
+
+// streamJSON sends payload encoded as JSON to the given REST path. Unlike
+// requests made through gopencils, the payload is encoded while it's being
+// sent, so no copy of the encoded request is kept in memory. The response is
+// decoded into result unless it is an error response, whose body is left
+// for newErrorStatus.
+func (api *API) streamJSON(
+	ctx context.Context,
+	method string,
+	path string,
+	payload interface{},
+	result interface{},
+) (*http.Response, error) {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(json.NewEncoder(writer).Encode(payload))
+	}()
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+
+	return api.streamRequest(ctx, method, path, reader, header, result)
+}
+
+// streamRequest sends the body read from the given pipe to the given REST
+// path and decodes the response into result like streamJSON does. The pipe
+// is closed once the request is done, which unblocks its writer if the
+// request failed before the whole body was sent.
+//
+// Requests are traced through the logger of the REST resource like the ones
+// made by gopencils, except that the streamed request body is left out.
+func (api *API) streamRequest(
+	ctx context.Context,
+	method string,
+	path string,
+	reader *io.PipeReader,
+	header http.Header,
+	result interface{},
+) (*http.Response, error) {
+	target := *api.rest.Api.BaseUrl
+	target.Path += "/" + path
+
+	request, err := http.NewRequestWithContext(ctx, method, target.String(), reader)
+	if err != nil {
+		_ = reader.Close()
+		return nil, err
+	}
+
+	for key, values := range header {
+		request.Header[key] = values
+	}
+
+	api.authorize(request)
+
+	logger := api.rest.Logger
+	if logger != nil {
+		dump, err := httputil.DumpRequest(request, false)
+		if err != nil {
+			logger.Printf("dump request failed: %s", err)
+		} else {
+			logger.Printf("%s<streamed body>", string(dump))
+		}
+	}
+
+	resp, err := api.rest.Api.Client.Do(request)
+
+	_ = reader.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if logger != nil {
+		dump, err := httputil.DumpResponse(resp, true)
+		if err != nil {
+			logger.Printf("dump response failed: %s", err)
+		} else {
+			logger.Printf("%s", string(dump))
+		}
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest ||
+		resp.StatusCode == http.StatusNoContent {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+
+	if api.CaptureUnknownFields && result != nil {
+		result = &unknownFieldsCapture{path: path, target: result}
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(result)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
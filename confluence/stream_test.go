@@ -0,0 +1,106 @@
+package confluence
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdatePageStreamsLargeBody(t *testing.T) {
+	content := strings.Repeat("<p>row</p>", streamThreshold/10+1)
+
+	var received string
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/rest/api/content/42", r.URL.Path)
+
+		// the length of a streamed body isn't known upfront
+		assert.Equal(t, int64(-1), r.ContentLength)
+
+		var payload struct {
+			Body struct {
+				Storage struct {
+					Value string `json:"value"`
+				} `json:"storage"`
+			} `json:"body"`
+		}
+
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		received = payload.Body.Storage.Value
+
+		_, _ = io.WriteString(w, `{}`)
+	})
+
+	err := api.UpdatePage(
+		&PageInfo{ID: "42", Title: "Large", Type: "page"},
+		content,
+		false,
+		"",
+		nil,
+		"fixed",
+		"",
+		false,
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, content, received)
+}
+
+func TestCreatePageStreamsLargeBodyError(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		_, _ = io.WriteString(w, `{"message": "too large"}`)
+	})
+
+	_, err := api.CreatePage(
+		"DOC",
+		"page",
+		nil,
+		"Large",
+		strings.Repeat("x", streamThreshold),
+	)
+	assert.ErrorContains(t, err, "too large")
+}
+
+type recordingLogger struct {
+	lines []string
+}
+
+func (logger *recordingLogger) Printf(format string, args ...interface{}) {
+	logger.lines = append(logger.lines, fmt.Sprintf(format, args...))
+}
+
+func TestStreamedRequestsAreTraced(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		_, _ = io.WriteString(w, `{"message": "too large"}`)
+	})
+
+	logger := &recordingLogger{}
+	api.rest.Logger = logger
+
+	_, err := api.CreatePage(
+		"DOC",
+		"page",
+		nil,
+		"Large",
+		strings.Repeat("x", streamThreshold),
+	)
+	assert.ErrorContains(t, err, "too large")
+
+	if assert.Len(t, logger.lines, 2) {
+		assert.Contains(t, logger.lines[0], "POST /rest/api/content/ HTTP/1.1")
+		assert.NotContains(t, logger.lines[0], "xxxx")
+		assert.Contains(t, logger.lines[1], "413 Request Entity Too Large")
+		assert.Contains(t, logger.lines[1], "too large")
+	}
+}
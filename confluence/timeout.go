@@ -0,0 +1,133 @@
+package confluence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ErrTimeout is wrapped by the errors of requests that exceeded API.Timeout.
+var ErrTimeout = errors.New("the Confluence API didn't respond in time")
+
+// timeoutTransport aborts requests that make no progress for longer than the
+// Timeout of api. Sending the request body and reading the response body
+// count as progress, so large transfers aren't cut off as long as data
+// keeps flowing; waiting for the response headers doesn't.
+type timeoutTransport struct {
+	api  *API
+	base http.RoundTripper
+}
+
+func (transport *timeoutTransport) RoundTrip(
+	request *http.Request,
+) (*http.Response, error) {
+	base := transport.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	timeout := transport.api.Timeout
+	if timeout <= 0 {
+		return base.RoundTrip(request)
+	}
+
+	parent := request.Context()
+
+	ctx, cancel := context.WithCancelCause(parent)
+	idle := &idleTimer{
+		timeout: timeout,
+		timer:   time.AfterFunc(timeout, func() { cancel(ErrTimeout) }),
+	}
+
+	request = request.WithContext(ctx)
+	if request.Body != nil && request.Body != http.NoBody {
+		request.Body = &idleBody{ReadCloser: request.Body, idle: idle}
+	}
+
+	resp, err := base.RoundTrip(request)
+	if err != nil {
+		idle.stop()
+		cancel(nil)
+
+		return nil, timeoutError(parent, ctx, err)
+	}
+
+	idle.touch()
+
+	resp.Body = &timeoutBody{
+		idleBody: idleBody{ReadCloser: resp.Body, idle: idle},
+		parent:   parent,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	return resp, nil
+}
+
+// idleTimer fires once it wasn't touched for timeout.
+type idleTimer struct {
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+func (idle *idleTimer) touch() {
+	idle.timer.Reset(idle.timeout)
+}
+
+func (idle *idleTimer) stop() {
+	idle.timer.Stop()
+}
+
+// idleBody touches idle whenever data is read from it.
+type idleBody struct {
+	io.ReadCloser
+
+	idle *idleTimer
+}
+
+func (body *idleBody) Read(data []byte) (int, error) {
+	n, err := body.ReadCloser.Read(data)
+	if n > 0 {
+		body.idle.touch()
+	}
+
+	return n, err
+}
+
+type timeoutBody struct {
+	idleBody
+
+	parent context.Context
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+}
+
+func (body *timeoutBody) Read(data []byte) (int, error) {
+	n, err := body.idleBody.Read(data)
+	if err != nil && err != io.EOF {
+		err = timeoutError(body.parent, body.ctx, err)
+	}
+
+	return n, err
+}
+
+func (body *timeoutBody) Close() error {
+	defer body.cancel(nil)
+
+	body.idle.stop()
+
+	return body.ReadCloser.Close()
+}
+
+// timeoutError wraps err with ErrTimeout if it was caused by ctx running out
+// of time rather than by parent being done.
+func timeoutError(parent, ctx context.Context, err error) error {
+	if parent.Err() != nil || context.Cause(ctx) != ErrTimeout {
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", ErrTimeout, err)
+}
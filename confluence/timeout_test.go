@@ -0,0 +1,109 @@
+package confluence
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeout(t *testing.T) {
+	var attempts atomic.Int32
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			<-r.Context().Done()
+			return
+		}
+
+		_, _ = io.WriteString(w, `{"id": "42"}`)
+	})
+
+	api.Timeout = 50 * time.Millisecond
+
+	// gopencils retries the request that timed out with a fresh timeout
+	page, err := api.GetPageByID("42")
+	assert.NoError(t, err)
+	assert.Equal(t, "42", page.ID)
+	assert.Equal(t, int32(2), attempts.Load())
+}
+
+func TestTimeoutError(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+
+	api.Timeout = 20 * time.Millisecond
+	api.RetryAttempts = 1
+
+	err := api.DeletePageContext(t.Context(), "42")
+	assert.ErrorIs(t, err, ErrTimeout)
+}
+
+func TestTimeoutRetriedWithContext(t *testing.T) {
+	var attempts atomic.Int32
+
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			<-r.Context().Done()
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	api.Timeout = 50 * time.Millisecond
+	api.RetryAttempts = 2
+
+	assert.NoError(t, api.DeletePageContext(t.Context(), "42"))
+	assert.Equal(t, int32(2), attempts.Load())
+}
+
+func TestTimeoutIsIdleTime(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+
+		// the response takes longer than the timeout as a whole, but
+		// data keeps flowing
+		_, _ = io.WriteString(w, `{"id": "42", "title": "`)
+		for i := 0; i < 6; i++ {
+			w.(http.Flusher).Flush()
+			time.Sleep(20 * time.Millisecond)
+			_, _ = io.WriteString(w, "x")
+		}
+		_, _ = io.WriteString(w, `"}`)
+	})
+
+	api.Timeout = 50 * time.Millisecond
+	api.RetryAttempts = 1
+
+	page, err := api.GetPageByID("42")
+	assert.NoError(t, err)
+	assert.Equal(t, "xxxxxx", page.Title)
+}
+
+func TestTimeoutSlowUpload(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_, _ = io.WriteString(w, `{"results": [{"id": "att1", "title": "a.txt"}]}`)
+	})
+
+	api.Timeout = 50 * time.Millisecond
+	api.RetryAttempts = 1
+
+	reader, writer := io.Pipe()
+	go func() {
+		for i := 0; i < 6; i++ {
+			time.Sleep(20 * time.Millisecond)
+			_, _ = io.WriteString(writer, "data")
+		}
+		_ = writer.Close()
+	}()
+
+	info, err := api.CreateAttachment("1", "a.txt", "", reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "att1", info.ID)
+}
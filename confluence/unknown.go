@@ -0,0 +1,95 @@
+package confluence
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/reconquest/pkg/log"
+)
+
+// unknownFieldsCapture decodes a response into target and collects the
+// top-level fields target has no field for. It's used in place of the result
+// of a resource if API.CaptureUnknownFields is set.
+type unknownFieldsCapture struct {
+	path   string
+	target interface{}
+}
+
+func (capture *unknownFieldsCapture) UnmarshalJSON(data []byte) error {
+	err := json.Unmarshal(data, capture.target)
+	if err != nil {
+		return err
+	}
+
+	value := reflect.ValueOf(capture.target)
+	for value.Kind() == reflect.Pointer && !value.IsNil() {
+		value = value.Elem()
+	}
+
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(data, &fields) != nil {
+		return nil
+	}
+
+	known := knownFields(value.Type())
+
+	unknown := map[string]json.RawMessage{}
+	for name, field := range fields {
+		if !known[strings.ToLower(name)] {
+			unknown[name] = field
+		}
+	}
+
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(unknown))
+	for name := range unknown {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	log.Debugf(
+		nil,
+		"response of %q has unknown fields: %s",
+		capture.path,
+		strings.Join(names, ", "),
+	)
+
+	field := value.FieldByName("UnknownFields")
+	if field.IsValid() && field.CanSet() &&
+		field.Type() == reflect.TypeOf(unknown) {
+		field.Set(reflect.ValueOf(unknown))
+	}
+
+	return nil
+}
+
+// knownFields returns the lowercased JSON names of the fields of the given
+// struct type, matching encoding/json's case-insensitive decoding.
+func knownFields(structType reflect.Type) map[string]bool {
+	known := map[string]bool{}
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = field.Name
+		}
+
+		known[strings.ToLower(name)] = true
+	}
+
+	return known
+}
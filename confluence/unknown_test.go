@@ -0,0 +1,35 @@
+package confluence
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaptureUnknownFields(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{
+			"id": "42",
+			"title": "Page",
+			"status": "current",
+			"version": {"number": 2, "collaborators": []}
+		}`)
+	})
+
+	page, err := api.GetPageByID("42")
+	assert.NoError(t, err)
+	assert.Nil(t, page.UnknownFields)
+
+	api.CaptureUnknownFields = true
+
+	page, err = api.GetPageByID("42")
+	assert.NoError(t, err)
+	assert.Equal(t, "Page", page.Title)
+	assert.Equal(t, int64(2), page.Version.Number)
+	assert.Equal(t, map[string]json.RawMessage{
+		"status": json.RawMessage(`"current"`),
+	}, page.UnknownFields)
+}
@@ -0,0 +1,28 @@
+package confluence
+
+import (
+	"fmt"
+)
+
+// GetWatchedPages returns the pages watched by the given user. On Cloud the
+// user is identified by account ID; names are resolved to one. Server
+// predates account IDs, so there user is the username.
+func (api *API) GetWatchedPages(user string) ([]PageInfo, error) {
+	watcher := user
+	if api.isCloud() {
+		accountID, err := api.resolveAccountID(user)
+		if err != nil {
+			return nil, err
+		}
+
+		watcher = accountID
+	}
+
+	query := map[string]string{
+		"cql":    fmt.Sprintf("type = page and watcher = %q", watcher),
+		"expand": "ancestors,version",
+		"limit":  "100",
+	}
+
+	return fetchPaged[PageInfo](api, "content/search", query)
+}
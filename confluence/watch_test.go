@@ -0,0 +1,75 @@
+package confluence
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetWatchedPagesServer(t *testing.T) {
+	api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/content/search", r.URL.Path)
+		assert.Equal(
+			t,
+			`type = page and watcher = "alice"`,
+			r.URL.Query().Get("cql"),
+		)
+
+		if r.URL.Query().Get("start") == "" {
+			_, _ = io.WriteString(w, `{
+				"results": [{"id": "1", "title": "Roadmap", "version": {"number": 4}}],
+				"_links": {"next": "/rest/api/content/search?cql=type+%3D+page+and+watcher+%3D+%22alice%22&start=1"}
+			}`)
+			return
+		}
+
+		_, _ = io.WriteString(w, `{
+			"results": [{"id": "2", "title": "Runbook", "ancestors": [{"id": "1", "title": "Roadmap"}]}],
+			"_links": {}
+		}`)
+	})
+
+	pages, err := api.GetWatchedPages("alice")
+	assert.NoError(t, err)
+	assert.Len(t, pages, 2)
+	assert.Equal(t, "Roadmap", pages[0].Title)
+	assert.Equal(t, int64(4), pages[0].Version.Number)
+	assert.Equal(t, "Runbook", pages[1].Title)
+	assert.Equal(t, "1", pages[1].Ancestors[0].ID)
+}
+
+func TestGetWatchedPagesCloud(t *testing.T) {
+	const accountID = "5b10a2844c20165700ede21a"
+
+	api := newCloudTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/wiki/rest/api/search/user":
+			_, _ = io.WriteString(w, `{"results": [
+				{"user": {"accountId": "`+accountID+`", "displayName": "Alice"}}
+			]}`)
+
+		case "/wiki/rest/api/content/search":
+			assert.Equal(
+				t,
+				`type = page and watcher = "`+accountID+`"`,
+				r.URL.Query().Get("cql"),
+			)
+
+			_, _ = io.WriteString(w, `{"results": [{"id": "1", "title": "Roadmap"}]}`)
+
+		default:
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+	})
+
+	pages, err := api.GetWatchedPages(accountID)
+	assert.NoError(t, err)
+	assert.Len(t, pages, 1)
+
+	// names are resolved to account IDs
+	pages, err = api.GetWatchedPages("Alice")
+	assert.NoError(t, err)
+	assert.Len(t, pages, 1)
+}
@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
+	"github.com/reconquest/karma-go"
 	"github.com/reconquest/pkg/log"
 )
 
@@ -22,6 +24,8 @@ const (
 	HeaderLabel       = `Label`
 	HeaderInclude     = `Include`
 	HeaderSidebar     = `Sidebar`
+	HeaderOwner       = `Owner`
+	HeaderDate        = `Date`
 	ContentAppearance = `Content-Appearance`
 )
 
@@ -35,7 +39,12 @@ type Meta struct {
 	Emoji             string
 	Attachments       []string
 	Labels            []string
+	Owner             string
 	ContentAppearance string
+
+	// Date is the creation date of the page, which is the posting day for
+	// blog posts. It is zero if not given.
+	Date time.Time
 }
 
 const (
@@ -118,6 +127,21 @@ func ExtractMeta(data []byte, spaceFromCli string, titleFromH1 bool, parents []s
 		case HeaderLabel:
 			meta.Labels = append(meta.Labels, value)
 
+		case HeaderOwner:
+			meta.Owner = strings.TrimSpace(value)
+
+		case HeaderDate:
+			date, err := time.Parse(time.DateOnly, strings.TrimSpace(value))
+			if err != nil {
+				return nil, nil, karma.Format(
+					err,
+					"unable to parse date %q, expected YYYY-MM-DD",
+					value,
+				)
+			}
+
+			meta.Date = date
+
 		case HeaderInclude:
 			// Includes are parsed by a different func
 			continue
@@ -14,16 +14,16 @@ func ResolvePage(
 	api *confluence.API,
 	meta *metadata.Meta,
 ) (*confluence.PageInfo, *confluence.PageInfo, error) {
-	page, err := api.FindPage(meta.Space, meta.Title, meta.Type)
-	if err != nil {
-		return nil, nil, karma.Format(
-			err,
-			"error while finding page %q",
-			meta.Title,
-		)
-	}
-
 	if meta.Type == "blogpost" {
+		page, err := api.FindBlogPost(meta.Space, meta.Title)
+		if err != nil {
+			return nil, nil, karma.Format(
+				err,
+				"error while finding blog post %q",
+				meta.Title,
+			)
+		}
+
 		log.Infof(
 			nil,
 			"blog post will be stored as: %s",
@@ -33,6 +33,15 @@ func ResolvePage(
 		return nil, page, nil
 	}
 
+	page, err := api.FindPage(meta.Space, meta.Title, meta.Type)
+	if err != nil {
+		return nil, nil, karma.Format(
+			err,
+			"error while finding page %q",
+			meta.Title,
+		)
+	}
+
 	// check to see if home page is in Parents
 	homepage, err := api.FindHomePage(meta.Space)
 	if err != nil {
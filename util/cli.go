@@ -9,7 +9,6 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
-	"slices"
 	"strings"
 	"time"
 
@@ -49,7 +48,24 @@ func RunMark(ctx context.Context, cmd *cli.Command) error {
 		return err
 	}
 
-	api := confluence.NewAPI(creds.BaseURL, creds.Username, creds.Password)
+	options := confluence.APIOptions{RetryAttempts: cmd.Int("retry-attempts")}
+
+	// without a username the password is a personal access token
+	var api *confluence.API
+	if creds.Username == "" {
+		api = confluence.NewAPIWithTokenOptions(creds.BaseURL, creds.Password, options)
+	} else {
+		api = confluence.NewAPIWithOptions(
+			creds.BaseURL,
+			creds.Username,
+			creds.Password,
+			options,
+		)
+	}
+
+	api.RetryOnConflict = cmd.Bool("retry-on-conflict")
+	api.Timeout = cmd.Duration("timeout")
+	api.MaxAttachmentSize = cmd.Int64("max-attachment-size")
 
 	files, err := doublestar.FilepathGlob(cmd.String("files"))
 	if err != nil {
@@ -242,12 +258,13 @@ func processFile(
 		}
 
 		if page == nil {
-			page, err = api.CreatePage(
+			page, err = api.CreatePageWithDate(
 				meta.Space,
 				meta.Type,
 				parent,
 				meta.Title,
 				``,
+				meta.Date,
 			)
 			if err != nil {
 				fatalErrorHandler.Handle(err, "can't create %s %q", meta.Type, meta.Title)
@@ -311,7 +328,7 @@ func processFile(
 	html, inlineAttachments := mark.CompileMarkdown(markdown, stdlib, file, cfg)
 
 	// Resolve attachements detected from markdown
-	_, err = attachment.ResolveAttachments(
+	inlineAttaches, err := attachment.ResolveAttachments(
 		api,
 		target,
 		inlineAttachments,
@@ -321,6 +338,19 @@ func processFile(
 		return nil
 	}
 
+	if cmd.Bool("prune-attachments") {
+		filenames := []string{}
+		for _, attach := range append(attaches, inlineAttaches...) {
+			filenames = append(filenames, attach.Filename)
+		}
+
+		_, err = api.PruneAttachments(target.ID, filenames)
+		if err != nil {
+			fatalErrorHandler.Handle(err, "unable to prune attachments")
+			return nil
+		}
+	}
+
 	{
 		var buffer bytes.Buffer
 
@@ -385,11 +415,66 @@ func processFile(
 	}
 
 	if shouldUpdatePage {
-		err = api.UpdatePage(target, html, cmd.Bool("minor-edit"), finalVersionMessage, meta.Labels, meta.ContentAppearance, meta.Emoji)
+		// The bookkeeping around the update is best effort: the page itself
+		// is what the user asked to publish, so failing to track it only
+		// warrants a warning.
+		edited, err := api.WasEditedExternally(target.ID, "")
+		if err != nil {
+			log.Warningf(err, "unable to check page %q for manual edits", target.Title)
+		} else if edited {
+			log.Warningf(
+				nil,
+				"page %q was edited outside of mark since it was published, "+
+					"these edits are overwritten",
+				target.Title,
+			)
+		}
+
+		err = api.UpdatePage(target, html, cmd.Bool("minor-edit"), finalVersionMessage, meta.Labels, meta.ContentAppearance, meta.Emoji, !cmd.Bool("force"))
 		if err != nil {
 			fatalErrorHandler.Handle(err, "unable to update page")
 			return nil
 		}
+
+		appearance, err := api.GetEffectiveAppearance(target.ID)
+		if err != nil {
+			log.Warningf(err, "unable to retrieve appearance of page %q", target.Title)
+		} else if appearance != meta.ContentAppearance {
+			log.Warningf(
+				nil,
+				"page %q is rendered %s instead of %s, the appearance is "+
+					"overridden by Confluence",
+				target.Title,
+				appearance,
+				meta.ContentAppearance,
+			)
+		}
+
+		err = api.SetContentProperty(
+			target.ID,
+			confluence.PropertySource,
+			confluence.SourceProperty{Path: filepath.ToSlash(file)},
+		)
+		if err != nil {
+			log.Warningf(err, "unable to store source of page %q", target.Title)
+		}
+
+		err = api.RecordBodyHash(target.ID)
+		if err != nil {
+			log.Warningf(err, "unable to store body hash of page %q", target.Title)
+		}
+
+		err = api.SetMarkVersion(target.ID, cmd.Root().Version)
+		if err != nil {
+			log.Warningf(err, "unable to store mark version of page %q", target.Title)
+		}
+
+		if meta.Owner != "" {
+			err = api.SetPageOwner(target.ID, meta.Owner)
+			if err != nil {
+				log.Warningf(err, "unable to set owner of page %q", target.Title)
+			}
+		}
 	}
 
 	if !updateLabels(api, target, meta, fatalErrorHandler) { // on error updating labels, return nil
@@ -415,69 +500,15 @@ func processFile(
 }
 
 func updateLabels(api *confluence.API, target *confluence.PageInfo, meta *metadata.Meta, fatalErrorHandler *FatalErrorHandler) bool {
-	labelInfo, err := api.GetPageLabels(target, "global")
+	err := api.ReconcileLabels(target, meta.Labels)
 	if err != nil {
-		log.Fatal(err)
-	}
-
-	log.Debug("Page Labels:")
-	log.Debug(labelInfo.Labels)
-
-	log.Debug("Meta Labels:")
-	log.Debug(meta.Labels)
-
-	delLabels := determineLabelsToRemove(labelInfo, meta)
-	log.Debug("Del Labels:")
-	log.Debug(delLabels)
-
-	addLabels := determineLabelsToAdd(meta, labelInfo)
-	log.Debug("Add Labels:")
-	log.Debug(addLabels)
-
-	if len(addLabels) > 0 {
-		_, err = api.AddPageLabels(target, addLabels)
-		if err != nil {
-			fatalErrorHandler.Handle(err, "error adding labels")
-			return false
-		}
+		fatalErrorHandler.Handle(err, "unable to update labels")
+		return false
 	}
 
-	for _, label := range delLabels {
-		_, err = api.DeletePageLabel(target, label)
-		if err != nil {
-			fatalErrorHandler.Handle(err, "error deleting labels")
-			return false
-		}
-	}
 	return true
 }
 
-// Page has label but label not in Metadata
-func determineLabelsToRemove(labelInfo *confluence.LabelInfo, meta *metadata.Meta) []string {
-	var labels []string
-	for _, label := range labelInfo.Labels {
-		if !slices.ContainsFunc(meta.Labels, func(metaLabel string) bool {
-			return strings.EqualFold(metaLabel, label.Name)
-		}) {
-			labels = append(labels, label.Name)
-		}
-	}
-	return labels
-}
-
-// Metadata has label but Page does not have it
-func determineLabelsToAdd(meta *metadata.Meta, labelInfo *confluence.LabelInfo) []string {
-	var labels []string
-	for _, metaLabel := range meta.Labels {
-		if !slices.ContainsFunc(labelInfo.Labels, func(label confluence.Label) bool {
-			return strings.EqualFold(label.Name, metaLabel)
-		}) {
-			labels = append(labels, metaLabel)
-		}
-	}
-	return labels
-}
-
 func ConfigFilePath() string {
 	fp, err := os.UserConfigDir()
 	if err != nil {
@@ -1,8 +1,11 @@
 package util
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
 
+	"github.com/kovetskiy/mark/confluence"
 	"github.com/reconquest/pkg/log"
 )
 
@@ -26,9 +29,35 @@ func (h *FatalErrorHandler) Handle(err error, format string, args ...interface{}
 		log.Fatal(fmt.Sprintf(format, args...))
 	}
 
+	if hint := errorHint(err); hint != "" {
+		log.Info(hint)
+	}
+
 	if h.ContinueOnError {
 		log.Errorf(err, format, args...)
 		return
 	}
 	log.Fatalf(err, format, args...)
 }
+
+// errorHint returns advice on how to fix the cause of err, if there is any.
+func errorHint(err error) string {
+	var notFound *confluence.SpaceNotFoundError
+	if errors.As(err, &notFound) {
+		return "check the Space header or the --space flag, space keys are case-sensitive"
+	}
+
+	var apiErr *confluence.APIError
+	if !errors.As(err, &apiErr) {
+		return ""
+	}
+
+	switch apiErr.StatusCode {
+	case http.StatusUnauthorized:
+		return "check your username and password or token"
+	case http.StatusForbidden:
+		return "check that your user has permission to edit the page"
+	default:
+		return ""
+	}
+}
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"github.com/kovetskiy/mark/confluence"
 	altsrc "github.com/urfave/cli-altsrc/v3"
 	altsrctoml "github.com/urfave/cli-altsrc/v3/toml"
 	"github.com/urfave/cli/v3"
@@ -79,6 +80,36 @@ var Flags = []cli.Flag{
 		Usage:   "add a message to the page version, to explain the edit (default: \"\")",
 		Sources: cli.NewValueSourceChain(cli.EnvVar("MARK_VERSION_MESSAGE"), altsrctoml.TOML("version-message", altsrc.NewStringPtrSourcer(&filename))),
 	},
+	&cli.BoolFlag{
+		Name:    "prune-attachments",
+		Value:   false,
+		Usage:   "delete attachments of the page that no longer have a source file.",
+		Sources: cli.NewValueSourceChain(cli.EnvVar("MARK_PRUNE_ATTACHMENTS"), altsrctoml.TOML("prune-attachments", altsrc.NewStringPtrSourcer(&filename))),
+	},
+	&cli.Int64Flag{
+		Name:    "max-attachment-size",
+		Value:   0,
+		Usage:   "reject attachments larger than this many bytes before uploading them, 0 disables the check.",
+		Sources: cli.NewValueSourceChain(cli.EnvVar("MARK_MAX_ATTACHMENT_SIZE"), altsrctoml.TOML("max-attachment-size", altsrc.NewStringPtrSourcer(&filename))),
+	},
+	&cli.BoolFlag{
+		Name:    "retry-on-conflict",
+		Value:   false,
+		Usage:   "retry updating a page that was modified concurrently, overwriting the modification.",
+		Sources: cli.NewValueSourceChain(cli.EnvVar("MARK_RETRY_ON_CONFLICT"), altsrctoml.TOML("retry-on-conflict", altsrc.NewStringPtrSourcer(&filename))),
+	},
+	&cli.IntFlag{
+		Name:    "retry-attempts",
+		Value:   5,
+		Usage:   "number of attempts for requests that are rate limited by Confluence.",
+		Sources: cli.NewValueSourceChain(cli.EnvVar("MARK_RETRY_ATTEMPTS"), altsrctoml.TOML("retry-attempts", altsrc.NewStringPtrSourcer(&filename))),
+	},
+	&cli.DurationFlag{
+		Name:    "timeout",
+		Value:   confluence.DefaultTimeout,
+		Usage:   "abort requests to Confluence that make no progress for this long, 0 disables the timeout.",
+		Sources: cli.NewValueSourceChain(cli.EnvVar("MARK_TIMEOUT"), altsrctoml.TOML("timeout", altsrc.NewStringPtrSourcer(&filename))),
+	},
 	&cli.StringFlag{
 		Name:  "color",
 		Value: "auto",
@@ -180,6 +211,12 @@ var Flags = []cli.Flag{
 		Usage:   "Avoids re-uploading pages that haven't changed since the last run.",
 		Sources: cli.NewValueSourceChain(cli.EnvVar("MARK_CHANGES_ONLY"), altsrctoml.TOML("changes-only", altsrc.NewStringPtrSourcer(&filename))),
 	},
+	&cli.BoolFlag{
+		Name:    "force",
+		Value:   false,
+		Usage:   "update pages even if their content, labels and appearance didn't change, creating a new version.",
+		Sources: cli.NewValueSourceChain(cli.EnvVar("MARK_FORCE"), altsrctoml.TOML("force", altsrc.NewStringPtrSourcer(&filename))),
+	},
 	&cli.FloatFlag{
 		Name:    "d2-scale",
 		Value:   1.0,